@@ -0,0 +1,67 @@
+package barcode
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/loyalsfc/investrite/utils"
+)
+
+type Symbology string
+
+const (
+	Code128 Symbology = "code128"
+	EAN13   Symbology = "ean13"
+)
+
+// Encode returns the modules of a barcode, true for a bar and false for a
+// space, without quiet zones.
+func Encode(symbology Symbology, text string) ([]bool, error) {
+	switch symbology {
+	case Code128:
+		return encodeCode128(text)
+	case EAN13:
+		return encodeEAN13(text)
+	default:
+		return nil, fmt.Errorf("unsupported symbology %v", symbology)
+	}
+}
+
+// ChooseCode picks what to print for an item. EAN-13 needs a valid retail
+// barcode; Code128 prints the SKU, falling back to the barcode. An empty
+// preference uses EAN-13 when possible.
+func ChooseCode(preferred Symbology, sku string, ean string) (Symbology, string, error) {
+	switch preferred {
+	case EAN13:
+		if !utils.IsValidBarcode(ean) {
+			return "", "", errors.New("item has no EAN-13 or UPC-A barcode")
+		}
+		return EAN13, ean, nil
+	case Code128:
+		if sku != "" {
+			return Code128, sku, nil
+		}
+		if ean != "" {
+			return Code128, ean, nil
+		}
+		return "", "", errors.New("item has no sku or barcode")
+	case "":
+		if utils.IsValidBarcode(ean) {
+			return EAN13, ean, nil
+		}
+		return ChooseCode(Code128, sku, ean)
+	default:
+		return "", "", fmt.Errorf("unsupported symbology %v", preferred)
+	}
+}
+
+func appendWidths(modules []bool, widths string) []bool {
+	bar := true
+	for _, width := range widths {
+		for i := 0; i < int(width-'0'); i++ {
+			modules = append(modules, bar)
+		}
+		bar = !bar
+	}
+	return modules
+}
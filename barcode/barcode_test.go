@@ -0,0 +1,156 @@
+package barcode
+
+import (
+	"bytes"
+	"image"
+	"strings"
+	"testing"
+)
+
+func modulesString(modules []bool) string {
+	var b strings.Builder
+	for _, module := range modules {
+		if module {
+			b.WriteByte('1')
+		} else {
+			b.WriteByte('0')
+		}
+	}
+	return b.String()
+}
+
+func TestEncodeEAN13(t *testing.T) {
+	modules, err := Encode(EAN13, "4006381333931")
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	bits := modulesString(modules)
+	if len(bits) != 95 {
+		t.Fatalf("got %d modules, want 95", len(bits))
+	}
+
+	if bits[:3] != "101" || bits[45:50] != "01010" || bits[92:] != "101" {
+		t.Errorf("guard bars misplaced in %v", bits)
+	}
+
+	//First digit 4 encodes the left half as L G L L G G, so 0 is L and 6 is G
+	if bits[3:10] != "0001101" || bits[10:17] != "0100111" {
+		t.Errorf("left half starts %v %v, want 0001101 0100111", bits[3:10], bits[10:17])
+	}
+
+	//The right half uses R codes; the check digit 1 is 1100110
+	if bits[85:92] != "1100110" {
+		t.Errorf("check digit encoded as %v, want 1100110", bits[85:92])
+	}
+}
+
+func TestEncodeUPCAMatchesEAN13(t *testing.T) {
+	upc, err := Encode(EAN13, "036000291452")
+	if err != nil {
+		t.Fatalf("Encode UPC-A: %v", err)
+	}
+
+	ean, err := Encode(EAN13, "0036000291452")
+	if err != nil {
+		t.Fatalf("Encode EAN-13: %v", err)
+	}
+
+	if modulesString(upc) != modulesString(ean) {
+		t.Error("UPC-A and its EAN-13 form encode differently")
+	}
+}
+
+func TestEncodeCode128(t *testing.T) {
+	modules, err := Encode(Code128, "A")
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	//Start B, "A" (value 33), checksum (104+33)%103 = 34, stop
+	want := "11010010000" + "10100011000" + "10001011000" + "1100011101011"
+	if got := modulesString(modules); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestEncodeRejectsInvalidInput(t *testing.T) {
+	tests := []struct {
+		symbology Symbology
+		text      string
+	}{
+		{EAN13, "4006381333932"},
+		{EAN13, "12345"},
+		{Code128, ""},
+		{Code128, "café"},
+		{"qr", "anything"},
+	}
+
+	for _, test := range tests {
+		if _, err := Encode(test.symbology, test.text); err == nil {
+			t.Errorf("Encode(%v, %q) succeeded, want an error", test.symbology, test.text)
+		}
+	}
+}
+
+func TestChooseCode(t *testing.T) {
+	tests := []struct {
+		name      string
+		preferred Symbology
+		sku       string
+		ean       string
+		want      Symbology
+		wantText  string
+		wantErr   bool
+	}{
+		{name: "default prefers a valid barcode", sku: "SKU-1", ean: "4006381333931", want: EAN13, wantText: "4006381333931"},
+		{name: "default falls back to the sku", sku: "SKU-1", ean: "123", want: Code128, wantText: "SKU-1"},
+		{name: "code128 prefers the sku", preferred: Code128, sku: "SKU-1", ean: "4006381333931", want: Code128, wantText: "SKU-1"},
+		{name: "code128 falls back to the barcode", preferred: Code128, ean: "123", want: Code128, wantText: "123"},
+		{name: "ean13 needs a valid barcode", preferred: EAN13, sku: "SKU-1", ean: "123", wantErr: true},
+		{name: "nothing to print", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			symbology, text, err := ChooseCode(test.preferred, test.sku, test.ean)
+
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("got %v %q, want an error", symbology, text)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ChooseCode: %v", err)
+			}
+
+			if symbology != test.want || text != test.wantText {
+				t.Errorf("got %v %q, want %v %q", symbology, text, test.want, test.wantText)
+			}
+		})
+	}
+}
+
+func TestWritePDFEmbedsRepeatedLabelsOnce(t *testing.T) {
+	size := LabelSize{WidthMM: 50, HeightMM: 30, DPI: 203}
+
+	label, err := RenderLabel(Label{Title: "rice", Caption: "100", Code: "SKU-1", Symbology: Code128}, size)
+	if err != nil {
+		t.Fatalf("RenderLabel: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := WritePDF(&out, []*image.Gray{label, label, label}, size, A4); err != nil {
+		t.Fatalf("WritePDF: %v", err)
+	}
+
+	if got := strings.Count(out.String(), "/Subtype /Image"); got != 1 {
+		t.Errorf("got %d embedded images, want 1", got)
+	}
+
+	if got := strings.Count(out.String(), " Do Q"); got != 3 {
+		t.Errorf("got %d placed labels, want 3", got)
+	}
+}
@@ -0,0 +1,80 @@
+package barcode
+
+import (
+	"fmt"
+
+	"github.com/loyalsfc/investrite/utils"
+)
+
+var (
+	eanLeftOdd = [10]string{
+		"0001101", "0011001", "0010011", "0111101", "0100011",
+		"0110001", "0101111", "0111011", "0110111", "0001011",
+	}
+
+	// eanParity selects odd (L) or even (G) encoding for the left half; the
+	// pattern itself encodes the first digit.
+	eanParity = [10]string{
+		"LLLLLL", "LLGLGG", "LLGGLG", "LLGGGL", "LGLLGG",
+		"LGGLLG", "LGGGLL", "LGLGLG", "LGLGGL", "LGGLGL",
+	}
+)
+
+// encodeEAN13 accepts EAN-13 codes and UPC-A codes, which are EAN-13 with a
+// leading zero.
+func encodeEAN13(code string) ([]bool, error) {
+	if !utils.IsValidBarcode(code) {
+		return nil, fmt.Errorf("%v is not a valid EAN-13 or UPC-A barcode", code)
+	}
+
+	if len(code) == 12 {
+		code = "0" + code
+	}
+
+	var modules []bool
+	appendBits := func(bits string) {
+		for _, bit := range bits {
+			modules = append(modules, bit == '1')
+		}
+	}
+
+	appendBits("101")
+
+	parity := eanParity[code[0]-'0']
+	for i := 1; i <= 6; i++ {
+		pattern := eanLeftOdd[code[i]-'0']
+		if parity[i-1] == 'G' {
+			pattern = reverse(invert(pattern))
+		}
+		appendBits(pattern)
+	}
+
+	appendBits("01010")
+
+	for i := 7; i <= 12; i++ {
+		appendBits(invert(eanLeftOdd[code[i]-'0']))
+	}
+
+	appendBits("101")
+	return modules, nil
+}
+
+func invert(bits string) string {
+	out := []byte(bits)
+	for i, bit := range out {
+		if bit == '1' {
+			out[i] = '0'
+		} else {
+			out[i] = '1'
+		}
+	}
+	return string(out)
+}
+
+func reverse(bits string) string {
+	out := []byte(bits)
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return string(out)
+}
@@ -0,0 +1,148 @@
+package barcode
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+type Label struct {
+	Title     string
+	Caption   string
+	Code      string
+	Symbology Symbology
+}
+
+type LabelSize struct {
+	WidthMM  float64
+	HeightMM float64
+	DPI      int
+}
+
+// MaxPixels bounds the greyscale pixels, one byte each, that a single print
+// job may hold in memory.
+const MaxPixels = 64 << 20
+
+// MaxStackedLabels is the most labels Stack is used for; larger jobs go to a
+// PDF, which embeds each distinct label only once.
+const MaxStackedLabels = 20
+
+func (s LabelSize) pixels() (int, int) {
+	width := int(math.Round(s.WidthMM / 25.4 * float64(s.DPI)))
+	height := int(math.Round(s.HeightMM / 25.4 * float64(s.DPI)))
+	return width, height
+}
+
+// Area is the number of pixels one rendered label takes.
+func (s LabelSize) Area() int {
+	width, height := s.pixels()
+	return width * height
+}
+
+// RenderLabel draws the title on top, the caption below it, the barcode in
+// the remaining space and the human readable code underneath.
+func RenderLabel(label Label, size LabelSize) (*image.Gray, error) {
+	modules, err := Encode(label.Symbology, label.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	width, height := size.pixels()
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+
+	margin := height / 20
+	scale := height / (glyphHeight * 9)
+	if scale < 1 {
+		scale = 1
+	}
+	lineHeight := (glyphHeight + 2) * scale
+
+	//Leave quiet zones of ten modules on both sides of the symbol
+	moduleWidth := (width - 2*margin) / (len(modules) + 20)
+	if moduleWidth < 1 {
+		return nil, errors.New("label is too narrow for this barcode")
+	}
+
+	barsTop := margin + 2*lineHeight
+	barsBottom := height - margin - lineHeight
+	if barsBottom-barsTop < height/5 {
+		return nil, errors.New("label is too short for this barcode")
+	}
+
+	drawText(img, label.Title, margin, margin, width-2*margin, scale)
+	drawText(img, label.Caption, margin, margin+lineHeight, width-2*margin, scale)
+
+	left := (width - len(modules)*moduleWidth) / 2
+	for i, bar := range modules {
+		if !bar {
+			continue
+		}
+
+		x := left + i*moduleWidth
+		draw.Draw(img, image.Rect(x, barsTop, x+moduleWidth, barsBottom), image.Black, image.Point{}, draw.Src)
+	}
+
+	codeWidth := len([]rune(label.Code)) * glyphAdvance * scale
+	drawText(img, label.Code, (width-codeWidth)/2, barsBottom+scale, width-2*margin, scale)
+
+	return img, nil
+}
+
+// drawText writes a single line, truncating it at maxWidth pixels.
+func drawText(img *image.Gray, text string, x int, y int, maxWidth int, scale int) {
+	if x < 0 {
+		x = 0
+	}
+
+	for _, char := range text {
+		if (glyphAdvance-1)*scale > maxWidth {
+			return
+		}
+
+		columns := glyph(char)
+		for col, bits := range columns {
+			for row := 0; row < glyphHeight; row++ {
+				if bits&(1<<row) == 0 {
+					continue
+				}
+
+				px := x + col*scale
+				py := y + row*scale
+				draw.Draw(img, image.Rect(px, py, px+scale, py+scale), image.Black, image.Point{}, draw.Src)
+			}
+		}
+
+		x = x + glyphAdvance*scale
+		maxWidth = maxWidth - glyphAdvance*scale
+	}
+}
+
+// Stack places labels under each other on one image, separated by gap pixels.
+// The same image may appear more than once.
+func Stack(labels []*image.Gray, gap int) *image.Gray {
+	width, height := 0, 0
+	for i, label := range labels {
+		if label.Bounds().Dx() > width {
+			width = label.Bounds().Dx()
+		}
+		height = height + label.Bounds().Dy()
+		if i > 0 {
+			height = height + gap
+		}
+	}
+
+	sheet := image.NewGray(image.Rect(0, 0, width, height))
+	draw.Draw(sheet, sheet.Bounds(), &image.Uniform{C: color.Gray{Y: 0xEE}}, image.Point{}, draw.Src)
+
+	y := 0
+	for _, label := range labels {
+		bounds := label.Bounds()
+		draw.Draw(sheet, image.Rect(0, y, bounds.Dx(), y+bounds.Dy()), label, bounds.Min, draw.Src)
+		y = y + bounds.Dy() + gap
+	}
+
+	return sheet
+}
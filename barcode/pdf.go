@@ -0,0 +1,148 @@
+package barcode
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+)
+
+// Sheet describes the page labels are laid out on, in millimetres.
+type Sheet struct {
+	PageWidthMM  float64
+	PageHeightMM float64
+	MarginMM     float64
+	GapMM        float64
+}
+
+var A4 = Sheet{PageWidthMM: 210, PageHeightMM: 297, MarginMM: 10, GapMM: 2}
+
+func mmToPt(mm float64) float64 {
+	return mm * 72 / 25.4
+}
+
+type pdfWriter struct {
+	buf     bytes.Buffer
+	offsets []int
+}
+
+// object reserves the next object number; write fills it in later.
+func (p *pdfWriter) object() int {
+	p.offsets = append(p.offsets, 0)
+	return len(p.offsets)
+}
+
+func (p *pdfWriter) write(id int, body string, stream []byte) {
+	p.offsets[id-1] = p.buf.Len()
+	fmt.Fprintf(&p.buf, "%d 0 obj\n%s\n", id, body)
+	if stream != nil {
+		p.buf.WriteString("stream\n")
+		p.buf.Write(stream)
+		p.buf.WriteString("\nendstream\n")
+	}
+	p.buf.WriteString("endobj\n")
+}
+
+// WritePDF lays labels out in a grid, left to right and top to bottom,
+// starting a new page whenever the current one is full. Every distinct label
+// is embedded once as a greyscale image at its rendered resolution, however
+// many times it appears in labels.
+func WritePDF(w io.Writer, labels []*image.Gray, size LabelSize, sheet Sheet) error {
+	if len(labels) == 0 {
+		return errors.New("no labels to write")
+	}
+
+	usableWidth := sheet.PageWidthMM - 2*sheet.MarginMM
+	usableHeight := sheet.PageHeightMM - 2*sheet.MarginMM
+	columns := int((usableWidth + sheet.GapMM) / (size.WidthMM + sheet.GapMM))
+	rows := int((usableHeight + sheet.GapMM) / (size.HeightMM + sheet.GapMM))
+	if columns < 1 || rows < 1 {
+		return errors.New("label does not fit on the page")
+	}
+	perPage := columns * rows
+
+	var pdf pdfWriter
+	pdf.buf.WriteString("%PDF-1.4\n")
+
+	catalog := pdf.object()
+	pages := pdf.object()
+
+	var images []int
+	embedded := map[*image.Gray]int{}
+	for _, label := range labels {
+		if id, ok := embedded[label]; ok {
+			images = append(images, id)
+			continue
+		}
+
+		id := pdf.object()
+		images = append(images, id)
+		embedded[label] = id
+
+		bounds := label.Bounds()
+		var raw bytes.Buffer
+		zw := zlib.NewWriter(&raw)
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			start := label.PixOffset(bounds.Min.X, y)
+			if _, err := zw.Write(label.Pix[start : start+bounds.Dx()]); err != nil {
+				return err
+			}
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+
+		body := fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceGray /BitsPerComponent 8 /Filter /FlateDecode /Length %d >>",
+			bounds.Dx(), bounds.Dy(), raw.Len())
+		pdf.write(id, body, raw.Bytes())
+	}
+
+	var kids []int
+	for first := 0; first < len(labels); first = first + perPage {
+		last := first + perPage
+		if last > len(labels) {
+			last = len(labels)
+		}
+
+		var content bytes.Buffer
+		var resources bytes.Buffer
+		for i := first; i < last; i++ {
+			slot := i - first
+			x := sheet.MarginMM + float64(slot%columns)*(size.WidthMM+sheet.GapMM)
+			top := sheet.MarginMM + float64(slot/columns)*(size.HeightMM+sheet.GapMM)
+			y := sheet.PageHeightMM - top - size.HeightMM
+
+			fmt.Fprintf(&content, "q %.2f 0 0 %.2f %.2f %.2f cm /Im%d Do Q\n",
+				mmToPt(size.WidthMM), mmToPt(size.HeightMM), mmToPt(x), mmToPt(y), i)
+			fmt.Fprintf(&resources, "/Im%d %d 0 R ", i, images[i])
+		}
+
+		contentID := pdf.object()
+		pdf.write(contentID, fmt.Sprintf("<< /Length %d >>", content.Len()), content.Bytes())
+
+		page := pdf.object()
+		kids = append(kids, page)
+		pdf.write(page, fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /XObject << %s>> >> /Contents %d 0 R >>",
+			pages, mmToPt(sheet.PageWidthMM), mmToPt(sheet.PageHeightMM), resources.String(), contentID), nil)
+	}
+
+	var kidRefs bytes.Buffer
+	for _, kid := range kids {
+		fmt.Fprintf(&kidRefs, "%d 0 R ", kid)
+	}
+
+	pdf.write(pages, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", kidRefs.String(), len(kids)), nil)
+	pdf.write(catalog, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pages), nil)
+
+	xref := pdf.buf.Len()
+	fmt.Fprintf(&pdf.buf, "xref\n0 %d\n0000000000 65535 f \n", len(pdf.offsets)+1)
+	for _, offset := range pdf.offsets {
+		fmt.Fprintf(&pdf.buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&pdf.buf, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(pdf.offsets)+1, catalog, xref)
+
+	_, err := w.Write(pdf.buf.Bytes())
+	return err
+}
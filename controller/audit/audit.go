@@ -0,0 +1,49 @@
+package audit
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/loyalsfc/investrite/data"
+	"github.com/loyalsfc/investrite/models"
+	"github.com/loyalsfc/investrite/response"
+	"github.com/loyalsfc/investrite/utils"
+)
+
+type AuditHandler struct {
+	AuditService models.AuditService
+}
+
+func (a AuditHandler) GetAuditEvents(ctx *gin.Context, userId uuid.UUID) {
+	var filter data.AuditFilter
+
+	if err := ctx.ShouldBindQuery(&filter); err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	var err error
+	filter.EntityID, err = utils.GetOptionalIDInQuery(ctx, "entity_id")
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	filter.ActorID, err = utils.GetOptionalIDInQuery(ctx, "actor_id")
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	events, meta, err := a.AuditService.GetAuditEvents(filter)
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	response.SuccessWithMeta(ctx, "audit events retrieved successfully", events, meta)
+}
@@ -2,20 +2,38 @@ package auth
 
 import (
 	"fmt"
+	"os"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/loyalsfc/investrite/data"
 	jsonformat "github.com/loyalsfc/investrite/jsonFormat"
+	"github.com/loyalsfc/investrite/mailer"
 	"github.com/loyalsfc/investrite/models"
 	"github.com/loyalsfc/investrite/response"
 	"github.com/loyalsfc/investrite/utils"
 )
 
 type AuthHandler struct {
-	UserService models.UserService
+	UserService  models.UserService
+	TokenService models.TokenService
+	Mailer       mailer.Sender
+}
+
+// registrationOpen reads OPEN_REGISTRATION. It defaults to on so existing
+// stores keep working; set it to false to onboard staff by invitation only.
+func registrationOpen() bool {
+	open, err := strconv.ParseBool(os.Getenv("OPEN_REGISTRATION"))
+	return err != nil || open
 }
 
 func (h AuthHandler) NewUser(ctx *gin.Context) {
+	if !registrationOpen() {
+		response.Error(ctx, 403, "registration is by invitation only")
+		return
+	}
+
 	var form data.FormData
 
 	if ctx.ShouldBind(&form) != nil {
@@ -30,6 +48,9 @@ func (h AuthHandler) NewUser(ctx *gin.Context) {
 		return
 	}
 
+	h.sendAccountMail(user, models.PurposeVerifyEmail)
+
+	utils.SetAuditEntry(ctx, utils.AuditEntry{EntityID: user.UserID, After: user})
 	response.Success(ctx, "user added successfully", user)
 }
 
@@ -49,12 +70,149 @@ func (h AuthHandler) Signin(ctx *gin.Context) {
 		return
 	}
 
-	signString, err := utils.GenerateToken(userInfo.UserID)
+	tokens, err := h.TokenService.IssueTokens(userInfo.UserID)
+
+	if err != nil {
+		response.Error(ctx, 500, fmt.Sprintf("error %v", err))
+		return
+	}
+
+	response.Success(ctx, "login successful", jsonformat.SignInToSignIn(userInfo, tokens))
+}
+
+func (h AuthHandler) Refresh(ctx *gin.Context) {
+	var params data.RefreshParams
+	ctx.Bind(&params)
+
+	if params.RefreshToken == "" {
+		response.Error(ctx, 400, "refresh_token is required")
+		return
+	}
+
+	tokens, err := h.TokenService.Refresh(params.RefreshToken)
+
+	if err != nil {
+		response.Error(ctx, 401, fmt.Sprintf("%v", err))
+		return
+	}
+
+	response.Success(ctx, "token refreshed", tokens)
+}
+
+func (h AuthHandler) Logout(ctx *gin.Context, userId uuid.UUID) {
+	var params data.LogoutParams
+	ctx.Bind(&params)
+
+	//The middleware has already verified this token, read it again for its jti
+	token, err := utils.GetAccessToken(&ctx.Request.Header)
+
+	if err != nil {
+		response.Error(ctx, 401, fmt.Sprintf("%v", err))
+		return
+	}
+
+	claims, err := utils.ParseToken(token)
+
+	if err != nil {
+		response.Error(ctx, 401, fmt.Sprintf("%v", err))
+		return
+	}
+
+	if err := h.TokenService.Logout(claims, params.RefreshToken, params.All); err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	response.Success(ctx, "logout successful", nil)
+}
+
+// JWKS is served bare rather than wrapped in the usual response envelope so
+// standard JWT libraries can read it.
+func (h AuthHandler) JWKS(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(200, utils.JWKS())
+}
+
+func (h AuthHandler) VerifyEmail(ctx *gin.Context) {
+	token := ctx.Query("token")
+
+	if token == "" {
+		response.Error(ctx, 400, "token is required")
+		return
+	}
+
+	userID, err := h.UserService.VerifyEmail(token)
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	utils.SetAuditEntry(ctx, utils.AuditEntry{EntityID: userID})
+	response.Success(ctx, "email verified", nil)
+}
+
+// ForgotPassword answers the same way whether or not the email has an
+// account, so it cannot be used to discover who is registered.
+func (h AuthHandler) ForgotPassword(ctx *gin.Context) {
+	var params data.ForgotPasswordParams
+	ctx.Bind(&params)
+
+	if params.Email == "" {
+		response.Error(ctx, 400, "email is required")
+		return
+	}
+
+	if user, err := h.UserService.GetUser(params.Email); err == nil {
+		h.sendAccountMail(user, models.PurposeResetPassword)
+	}
+
+	response.Success(ctx, "if the email is registered, a reset link has been sent", nil)
+}
+
+func (h AuthHandler) ResetPassword(ctx *gin.Context) {
+	var params data.ResetPasswordParams
+	ctx.Bind(&params)
+
+	if params.Token == "" {
+		response.Error(ctx, 400, "token is required")
+		return
+	}
+
+	userID, err := h.UserService.ResetPassword(params.Token, params.Password)
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	utils.SetAuditEntry(ctx, utils.AuditEntry{EntityID: userID})
+	response.Success(ctx, "password reset successful", nil)
+}
+
+func (h AuthHandler) AcceptInvite(ctx *gin.Context) {
+	var params data.AcceptInviteParams
+	ctx.Bind(&params)
+
+	if params.Token == "" {
+		response.Error(ctx, 400, "token is required")
+		return
+	}
+
+	user, err := h.UserService.AcceptInvitation(params)
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	tokens, err := h.TokenService.IssueTokens(user.UserID)
 
 	if err != nil {
 		response.Error(ctx, 500, fmt.Sprintf("error %v", err))
 		return
 	}
 
-	response.Success(ctx, "login successful", jsonformat.SignInToSignIn(userInfo, signString))
+	utils.SetAuditEntry(ctx, utils.AuditEntry{EntityID: user.UserID, After: user})
+	response.Success(ctx, "invitation accepted", jsonformat.SignInToSignIn(user, tokens))
 }
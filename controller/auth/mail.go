@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/loyalsfc/investrite/mailer"
+	"github.com/loyalsfc/investrite/models"
+)
+
+// sendAccountMail issues a token and mails it in the background so the
+// response time does not reveal whether the address has an account.
+func (h AuthHandler) sendAccountMail(user *models.User, purpose string) {
+	go func() {
+		token, err := h.UserService.CreateAccountToken(user.UserID, purpose)
+		if err != nil {
+			log.Printf("%v token for %v failed: %v", purpose, user.Email, err)
+			return
+		}
+
+		var msg mailer.Message
+
+		switch purpose {
+		case models.PurposeVerifyEmail:
+			msg = mailer.Message{
+				To:      user.Email,
+				Subject: "Verify your email address",
+				Body: fmt.Sprintf("Hi %v,\n\nConfirm your email address by opening this link within 48 hours:\n\n%v\n",
+					user.FirstName, mailer.AppLink("/auth/verify-email", token)),
+			}
+		case models.PurposeResetPassword:
+			msg = mailer.Message{
+				To:      user.Email,
+				Subject: "Reset your password",
+				Body: fmt.Sprintf("Hi %v,\n\nSomeone asked to reset your password. Use this link within an hour to choose a new one:\n\n%v\n\nIf it was not you, ignore this email and your password will stay the same.\n",
+					user.FirstName, mailer.AppLink("/reset-password", token)),
+			}
+		}
+
+		if err := h.Mailer.Send(msg); err != nil {
+			log.Printf("sending %v mail to %v failed: %v", purpose, user.Email, err)
+		}
+	}()
+}
@@ -5,7 +5,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"github.com/loyalsfc/investrite/controller/role"
+	"github.com/loyalsfc/investrite/data"
 	"github.com/loyalsfc/investrite/models"
 	"github.com/loyalsfc/investrite/response"
 	"github.com/loyalsfc/investrite/utils"
@@ -24,18 +24,6 @@ func (c CategoryHandler) NewCategory(ctx *gin.Context, userId uuid.UUID) {
 
 	ctx.Bind(&name)
 
-	userRole, err := role.GetUserRole(userId)
-
-	if err != nil {
-		response.Error(ctx, 403, fmt.Sprintf("role error %v", err))
-		return
-	}
-
-	if utils.RoleLevel(userRole) < 3 {
-		response.PermissionError(ctx)
-		return
-	}
-
 	category, err := c.CategoryService.CreateCategory(name.Name)
 
 	if err != nil {
@@ -43,22 +31,11 @@ func (c CategoryHandler) NewCategory(ctx *gin.Context, userId uuid.UUID) {
 		return
 	}
 
+	utils.SetAuditEntry(ctx, utils.AuditEntry{EntityID: category.ID, After: category})
 	response.Success(ctx, "new category created", category)
 }
 
 func (c CategoryHandler) EditCategory(ctx *gin.Context, userId uuid.UUID) {
-	userRole, err := role.GetUserRole(userId)
-
-	if err != nil {
-		response.Error(ctx, 403, fmt.Sprintf("role error %v", err))
-		return
-	}
-
-	if utils.RoleLevel(userRole) < 3 {
-		response.PermissionError(ctx)
-		return
-	}
-
 	catID, err := utils.GetIdFromParams(ctx)
 
 	if err != nil {
@@ -80,18 +57,6 @@ func (c CategoryHandler) EditCategory(ctx *gin.Context, userId uuid.UUID) {
 }
 
 func (c CategoryHandler) DeleteCategory(ctx *gin.Context, userId uuid.UUID) {
-	userRole, err := role.GetUserRole(userId)
-
-	if err != nil {
-		response.Error(ctx, 403, fmt.Sprintf("role error %v", err))
-		return
-	}
-
-	if utils.RoleLevel(userRole) < 3 {
-		response.PermissionError(ctx)
-		return
-	}
-
 	catID, err := utils.GetIdFromParams(ctx)
 
 	if err != nil {
@@ -110,25 +75,21 @@ func (c CategoryHandler) DeleteCategory(ctx *gin.Context, userId uuid.UUID) {
 }
 
 func (c CategoryHandler) GetCategories(ctx *gin.Context, userId uuid.UUID) {
-	userRole, err := role.GetUserRole(userId)
+	var params data.ListParams
 
-	if err != nil {
-		response.Error(ctx, 403, fmt.Sprintf("role error %v", err))
+	if err := ctx.ShouldBindQuery(&params); err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
 		return
 	}
 
-	if utils.RoleLevel(userRole) < 1 {
-		response.PermissionError(ctx)
-		return
-	}
-	categories, err := c.CategoryService.CategoryList()
+	categories, meta, err := c.CategoryService.CategoryList(params)
 
 	if err != nil {
-		response.Error(ctx, 401, fmt.Sprintf("%v", err))
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
 		return
 	}
 
-	response.Success(ctx, "category list succesful", categories)
+	response.SuccessWithMeta(ctx, "category list succesful", categories, meta)
 }
 
 func (c CategoryHandler) GetCategory(ctx *gin.Context, userId uuid.UUID) {
@@ -139,17 +100,6 @@ func (c CategoryHandler) GetCategory(ctx *gin.Context, userId uuid.UUID) {
 		return
 	}
 
-	userRole, err := role.GetUserRole(userId)
-
-	if err != nil {
-		response.Error(ctx, 403, fmt.Sprintf("role error %v", err))
-		return
-	}
-
-	if utils.RoleLevel(userRole) < 1 {
-		response.PermissionError(ctx)
-		return
-	}
 	category, err := c.CategoryService.FindCategoryById(productId)
 
 	if err != nil {
@@ -1,19 +1,24 @@
 package items
 
 import (
+	"bytes"
 	"fmt"
+	"image"
+	"image/png"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"github.com/loyalsfc/investrite/controller/role"
+	"github.com/loyalsfc/investrite/barcode"
 	"github.com/loyalsfc/investrite/data"
 	"github.com/loyalsfc/investrite/models"
 	"github.com/loyalsfc/investrite/response"
+	"github.com/loyalsfc/investrite/spreadsheet"
 	"github.com/loyalsfc/investrite/utils"
 )
 
 type ProductHandler struct {
 	ProductService models.ProductService
+	AlertService   models.AlertService
 }
 
 func (h ProductHandler) NewProduct(ctx *gin.Context, userId uuid.UUID) {
@@ -21,29 +26,66 @@ func (h ProductHandler) NewProduct(ctx *gin.Context, userId uuid.UUID) {
 
 	ctx.Bind(&params)
 
-	userRole, err := role.GetUserRole(userId)
+	product, err := h.ProductService.CreateProduct(&params, userId)
 
 	if err != nil {
-		response.Error(ctx, 403, fmt.Sprintf("role error %v", err))
+		response.Error(ctx, 403, fmt.Sprintf("%v", err))
 		return
 	}
 
-	if utils.RoleLevel(userRole) < 3 {
-		response.PermissionError(ctx)
+	utils.SetAuditEntry(ctx, utils.AuditEntry{EntityID: product.ID, After: product})
+	response.Success(ctx, "product added successfully", product)
+}
+
+func (h ProductHandler) GetProduct(ctx *gin.Context, userId uuid.UUID) {
+	productId, err := utils.GetIDInRoute(ctx, "productID")
+
+	if err != nil {
+		response.Error(ctx, 403, fmt.Sprintf("%v", err))
 		return
 	}
 
-	product, err := h.ProductService.CreateProduct(&params)
+	product, err := h.ProductService.GetProductDetail(productId)
 
 	if err != nil {
-		response.Error(ctx, 403, fmt.Sprintf("%v", err))
+		response.Error(ctx, 404, fmt.Sprintf("%v", err))
 		return
 	}
 
-	response.Success(ctx, "product added successfully", product)
+	response.Success(ctx, "product retrieved successfully", product)
 }
 
-func (h ProductHandler) GetProduct(ctx *gin.Context, userId uuid.UUID) {
+func (h ProductHandler) GetProducts(ctx *gin.Context, userId uuid.UUID) {
+	var filter data.ProductFilter
+
+	if err := ctx.ShouldBindQuery(&filter); err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	var err error
+	filter.CategoryID, err = utils.GetOptionalIDInQuery(ctx, "category_id")
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	products, meta, err := h.ProductService.GetAllProducts(filter)
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	response.SuccessWithMeta(ctx, "product retrieved successfully", products, meta)
+}
+
+func (h ProductHandler) UpdateProduct(ctx *gin.Context, userId uuid.UUID) {
+	var params data.AddProductParams
+
+	ctx.Bind(&params)
+
 	productId, err := utils.GetIDInRoute(ctx, "productID")
 
 	if err != nil {
@@ -51,171 +93,495 @@ func (h ProductHandler) GetProduct(ctx *gin.Context, userId uuid.UUID) {
 		return
 	}
 
-	userRole, err := role.GetUserRole(userId)
+	productErr := h.ProductService.UpdateProduct(productId, &params, userId)
+
+	if productErr != nil {
+		response.Error(ctx, 404, fmt.Sprintf("%v", productErr))
+		return
+	}
+
+	response.Success(ctx, "product updated successfully", nil)
+}
+
+func (h ProductHandler) DeleteProduct(ctx *gin.Context, userId uuid.UUID) {
+	productId, err := utils.GetIDInRoute(ctx, "productID")
 
 	if err != nil {
 		response.Error(ctx, 403, fmt.Sprintf("%v", err))
 		return
 	}
 
-	if utils.RoleLevel(userRole) < 1 {
-		response.PermissionError(ctx)
+	productErr := h.ProductService.DeleteProduct(productId)
+
+	if productErr != nil {
+		response.Error(ctx, 404, fmt.Sprintf("%v", productErr))
+		return
+	}
+
+	response.Success(ctx, "product deleted", nil)
+}
+
+func (h ProductHandler) IncreaseProductQuantity(ctx *gin.Context, userId uuid.UUID) {
+	productId, err := utils.GetIDInRoute(ctx, "productID")
+
+	if err != nil {
+		response.Error(ctx, 403, fmt.Sprintf("%v", err))
 		return
 	}
 
-	product, err := h.ProductService.GetProductById(productId)
+	locationId, err := utils.GetOptionalIDInQuery(ctx, "location_id")
 
 	if err != nil {
-		response.Error(ctx, 404, fmt.Sprintf("%v", err))
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
 		return
 	}
 
-	response.Success(ctx, "product retrieved successfully", product)
+	qty, productErr := h.ProductService.IncrementQuantity(productId, locationId, userId)
+
+	if productErr != nil {
+		response.Error(ctx, 404, fmt.Sprintf("%v", productErr))
+		return
+	}
+
+	response.Success(ctx, "product quantity increased", qty)
 }
 
-func (h ProductHandler) GetProducts(ctx *gin.Context, userId uuid.UUID) {
-	userRole, err := role.GetUserRole(userId)
+func (h ProductHandler) DecreaseProductQuantity(ctx *gin.Context, userId uuid.UUID) {
+	productId, err := utils.GetIDInRoute(ctx, "productID")
 
 	if err != nil {
 		response.Error(ctx, 403, fmt.Sprintf("%v", err))
 		return
 	}
 
-	if utils.RoleLevel(userRole) < 1 {
-		response.PermissionError(ctx)
+	locationId, err := utils.GetOptionalIDInQuery(ctx, "location_id")
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	qty, productErr := h.ProductService.DecreaseQuantity(productId, locationId, userId)
+
+	if productErr != nil {
+		response.Error(ctx, 404, fmt.Sprintf("%v", productErr))
+		return
+	}
+
+	response.Success(ctx, "product quantity decreased", qty)
+}
+
+func (h ProductHandler) GetProductMovements(ctx *gin.Context, userId uuid.UUID) {
+	productId, err := utils.GetIDInRoute(ctx, "productID")
+
+	if err != nil {
+		response.Error(ctx, 403, fmt.Sprintf("%v", err))
 		return
 	}
 
-	products, err := h.ProductService.GetAllProducts()
+	ledger, err := h.ProductService.GetStockLedger(productId)
 
 	if err != nil {
 		response.Error(ctx, 404, fmt.Sprintf("%v", err))
 		return
 	}
 
-	response.Success(ctx, "product retrieved successfully", products)
+	response.Success(ctx, "stock movements retrieved successfully", ledger)
 }
 
-func (h ProductHandler) UpdateProduct(ctx *gin.Context, userId uuid.UUID) {
-	var params data.AddProductParams
+func (h ProductHandler) AdjustProductQuantities(ctx *gin.Context, userId uuid.UUID) {
+	var params data.AdjustQuantityParams
 
 	ctx.Bind(&params)
 
-	productId, err := utils.GetIDInRoute(ctx, "productID")
+	//Moving stock by a delta needs stock:adjust, overwriting counts stock:overwrite
+	for _, adjustment := range params.Adjustments {
+		if adjustment.AbsoluteCount != nil && !utils.HasPermission(ctx, utils.PermStockOverwrite) {
+			response.PermissionError(ctx)
+			return
+		}
+	}
+
+	results, err := h.ProductService.AdjustQuantities(params, userId)
 
 	if err != nil {
 		response.Error(ctx, 403, fmt.Sprintf("%v", err))
 		return
 	}
 
-	userRole, err := role.GetUserRole(userId)
+	utils.SetAuditEntry(ctx, utils.AuditEntry{After: results})
+	response.Success(ctx, "product quantities adjusted", results)
+}
+
+func (h ProductHandler) ImportProducts(ctx *gin.Context, userId uuid.UUID) {
+	var params data.ImportProductsParams
+
+	if err := ctx.ShouldBind(&params); err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	locationId, err := utils.GetOptionalIDInQuery(ctx, "location_id")
 
 	if err != nil {
-		response.Error(ctx, 403, fmt.Sprintf("%v", err))
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
 		return
 	}
+	params.LocationID = locationId
 
-	if utils.RoleLevel(userRole) < 3 {
-		response.PermissionError(ctx)
+	header, err := ctx.FormFile("file")
+
+	if err != nil {
+		response.Error(ctx, 400, "upload the spreadsheet as the file field")
+		return
+	}
+
+	if header.Size > 10<<20 {
+		response.Error(ctx, 400, "spreadsheet cannot be larger than 10MB")
 		return
 	}
 
-	productErr := h.ProductService.UpdateProduct(productId, &params)
+	file, err := header.Open()
 
-	if productErr != nil {
-		response.Error(ctx, 404, fmt.Sprintf("%v", productErr))
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
 		return
 	}
+	defer file.Close()
 
-	response.Success(ctx, "product updated successfully", nil)
+	rows, err := spreadsheet.Read(header.Filename, file, models.MaxImportRows+1)
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	result, err := h.ProductService.ImportProducts(rows, params, userId)
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	message := "products imported"
+	if params.DryRun {
+		message = "import validated, nothing was saved"
+	}
+
+	utils.SetAuditEntry(ctx, utils.AuditEntry{After: result})
+	response.Success(ctx, message, result)
 }
 
-func (h ProductHandler) DeleteProduct(ctx *gin.Context, userId uuid.UUID) {
-	productId, err := utils.GetIDInRoute(ctx, "productID")
+func (h ProductHandler) SearchProducts(ctx *gin.Context, userId uuid.UUID) {
+	var params data.ListParams
+
+	if err := ctx.ShouldBindQuery(&params); err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	results, meta, err := h.ProductService.SearchProducts(ctx.Query("q"), params)
 
 	if err != nil {
-		response.Error(ctx, 403, fmt.Sprintf("%v", err))
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
 		return
 	}
 
-	userRole, err := role.GetUserRole(userId)
+	response.SuccessWithMeta(ctx, "search results retrieved successfully", results, meta)
+}
+
+func (h ProductHandler) LookupProduct(ctx *gin.Context, userId uuid.UUID) {
+	match, err := h.ProductService.LookupCode(ctx.Query("code"))
 
 	if err != nil {
-		response.Error(ctx, 403, fmt.Sprintf("%v", err))
+		response.Error(ctx, 404, fmt.Sprintf("%v", err))
 		return
 	}
 
-	if utils.RoleLevel(userRole) < 3 {
-		response.PermissionError(ctx)
+	response.Success(ctx, "product retrieved successfully", match)
+}
+
+func (h ProductHandler) GetLowStockProducts(ctx *gin.Context, userId uuid.UUID) {
+	products, err := h.ProductService.GetLowStockProducts()
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
 		return
 	}
 
-	productErr := h.ProductService.DeleteProduct(productId)
+	response.Success(ctx, "low stock products retrieved successfully", products)
+}
 
-	if productErr != nil {
-		response.Error(ctx, 404, fmt.Sprintf("%v", productErr))
+func (h ProductHandler) GetStockAlerts(ctx *gin.Context, userId uuid.UUID) {
+	alerts, err := h.AlertService.GetAlerts(ctx.Query("all") == "true")
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
 		return
 	}
 
-	response.Success(ctx, "product deleted", nil)
+	response.Success(ctx, "stock alerts retrieved successfully", alerts)
 }
 
-func (h ProductHandler) IncreaseProductQuantity(ctx *gin.Context, userId uuid.UUID) {
+func (h ProductHandler) SetProductOptions(ctx *gin.Context, userId uuid.UUID) {
+	var params data.SetProductOptionsParams
+	ctx.Bind(&params)
+
 	productId, err := utils.GetIDInRoute(ctx, "productID")
 
 	if err != nil {
-		response.Error(ctx, 403, fmt.Sprintf("%v", err))
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
 		return
 	}
 
-	userRole, err := role.GetUserRole(userId)
+	options, err := h.ProductService.SetProductOptions(productId, params.Options)
 
 	if err != nil {
 		response.Error(ctx, 403, fmt.Sprintf("%v", err))
 		return
 	}
 
-	if utils.RoleLevel(userRole) < 2 {
-		response.PermissionError(ctx)
+	response.Success(ctx, "product options updated", options)
+}
+
+func (h ProductHandler) CreateVariant(ctx *gin.Context, userId uuid.UUID) {
+	var params data.VariantParams
+	ctx.Bind(&params)
+
+	productId, err := utils.GetIDInRoute(ctx, "productID")
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
 		return
 	}
 
-	qty, productErr := h.ProductService.IncrementQuantity(productId)
+	variant, err := h.ProductService.CreateVariant(productId, params, userId)
 
-	if productErr != nil {
-		response.Error(ctx, 404, fmt.Sprintf("%v", productErr))
+	if err != nil {
+		response.Error(ctx, 403, fmt.Sprintf("%v", err))
 		return
 	}
 
-	response.Success(ctx, "product quantity increased", qty)
+	response.Success(ctx, "variant added successfully", variant)
 }
 
-func (h ProductHandler) DecreaseProductQuantity(ctx *gin.Context, userId uuid.UUID) {
+func (h ProductHandler) UpdateVariant(ctx *gin.Context, userId uuid.UUID) {
+	var params data.VariantParams
+	ctx.Bind(&params)
+
 	productId, err := utils.GetIDInRoute(ctx, "productID")
 
 	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	variantId, err := utils.GetIDInRoute(ctx, "variantID")
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	if err := h.ProductService.UpdateVariant(productId, variantId, params); err != nil {
 		response.Error(ctx, 403, fmt.Sprintf("%v", err))
 		return
 	}
 
-	userRole, err := role.GetUserRole(userId)
+	response.Success(ctx, "variant updated successfully", nil)
+}
+
+func (h ProductHandler) DeleteVariant(ctx *gin.Context, userId uuid.UUID) {
+	productId, err := utils.GetIDInRoute(ctx, "productID")
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	variantId, err := utils.GetIDInRoute(ctx, "variantID")
 
 	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	if err := h.ProductService.DeleteVariant(productId, variantId); err != nil {
 		response.Error(ctx, 403, fmt.Sprintf("%v", err))
 		return
 	}
 
-	if utils.RoleLevel(userRole) < 2 {
-		response.PermissionError(ctx)
+	response.Success(ctx, "variant deleted successfully", nil)
+}
+
+func (h ProductHandler) PrintLabels(ctx *gin.Context, userId uuid.UUID) {
+	var params data.LabelParams
+	ctx.Bind(&params)
+
+	size := barcode.LabelSize{WidthMM: params.WidthMM, HeightMM: params.HeightMM, DPI: params.DPI}
+	if size.WidthMM == 0 {
+		size.WidthMM = 50
+	}
+	if size.HeightMM == 0 {
+		size.HeightMM = 30
+	}
+	if size.DPI == 0 {
+		size.DPI = 203
+	}
+
+	if size.WidthMM < 20 || size.WidthMM > 200 || size.HeightMM < 10 || size.HeightMM > 150 || size.DPI < 72 || size.DPI > 600 {
+		response.Error(ctx, 400, "label size must be 20-200mm wide, 10-150mm high and 72-600 dpi")
 		return
 	}
 
-	qty, productErr := h.ProductService.DecreaseQuantity(productId)
+	if params.Format != "" && params.Format != "png" && params.Format != "pdf" {
+		response.Error(ctx, 400, "format must be png or pdf")
+		return
+	}
 
-	if productErr != nil {
-		response.Error(ctx, 404, fmt.Sprintf("%v", productErr))
+	items, err := h.ProductService.GetLabelItems(params.Items)
+
+	if err != nil {
+		response.Error(ctx, 404, fmt.Sprintf("%v", err))
 		return
 	}
 
-	response.Success(ctx, "product quantity decreased", qty)
+	//A PNG holds every copy on one canvas; a PDF embeds each label once
+	copies := 0
+	for _, item := range items {
+		copies = copies + item.Copies
+	}
+
+	rendered := len(items) * size.Area()
+	if params.Format != "pdf" {
+		if copies > barcode.MaxStackedLabels {
+			response.Error(ctx, 400, fmt.Sprintf("a png holds at most %v labels, use pdf for more", barcode.MaxStackedLabels))
+			return
+		}
+
+		rendered = rendered + copies*size.Area()
+	}
+
+	if rendered > barcode.MaxPixels {
+		response.Error(ctx, 400, "too many labels at this size and resolution, print fewer, smaller or lower dpi labels")
+		return
+	}
+
+	var labels []*image.Gray
+	for _, item := range items {
+		symbology, code, err := barcode.ChooseCode(barcode.Symbology(params.Symbology), item.SKU, item.Barcode)
+
+		if err != nil {
+			response.Error(ctx, 400, fmt.Sprintf("%v: %v", item.Name, err))
+			return
+		}
+
+		label, err := barcode.RenderLabel(barcode.Label{
+			Title:     item.Name,
+			Caption:   fmt.Sprintf("%d", item.Price),
+			Code:      code,
+			Symbology: symbology,
+		}, size)
+
+		if err != nil {
+			response.Error(ctx, 400, fmt.Sprintf("%v: %v", item.Name, err))
+			return
+		}
+
+		for i := 0; i < item.Copies; i++ {
+			labels = append(labels, label)
+		}
+	}
+
+	var out bytes.Buffer
+
+	switch params.Format {
+	case "", "png":
+		if err := png.Encode(&out, barcode.Stack(labels, size.DPI/25)); err != nil {
+			response.Error(ctx, 500, fmt.Sprintf("%v", err))
+			return
+		}
+
+		ctx.Header("Content-Disposition", "inline; filename=labels.png")
+		ctx.Data(200, "image/png", out.Bytes())
+	case "pdf":
+		if err := barcode.WritePDF(&out, labels, size, barcode.A4); err != nil {
+			response.Error(ctx, 400, fmt.Sprintf("%v", err))
+			return
+		}
+
+		ctx.Header("Content-Disposition", "inline; filename=labels.pdf")
+		ctx.Data(200, "application/pdf", out.Bytes())
+	}
+}
+
+func (h ProductHandler) ExportProducts(ctx *gin.Context, userId uuid.UUID) {
+	format := ctx.DefaultQuery("format", "csv")
+	if !spreadsheet.IsValidFormat(format) {
+		response.Error(ctx, 400, "format must be csv, xlsx or ndjson")
+		return
+	}
+
+	var filter data.ProductFilter
+
+	if err := ctx.ShouldBindQuery(&filter); err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	var err error
+	filter.CategoryID, err = utils.GetOptionalIDInQuery(ctx, "category_id")
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	export, err := h.ProductService.ExportProducts(filter)
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	response.Export(ctx, "products", format, export)
+}
+
+func (h ProductHandler) ExportMovements(ctx *gin.Context, userId uuid.UUID) {
+	format := ctx.DefaultQuery("format", "csv")
+	if !spreadsheet.IsValidFormat(format) {
+		response.Error(ctx, 400, "format must be csv, xlsx or ndjson")
+		return
+	}
+
+	var filter data.MovementFilter
+
+	if err := ctx.ShouldBindQuery(&filter); err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	var err error
+	filter.ProductID, err = utils.GetOptionalIDInQuery(ctx, "product_id")
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	filter.LocationID, err = utils.GetOptionalIDInQuery(ctx, "location_id")
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	export, err := h.ProductService.ExportMovements(filter)
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	response.Export(ctx, "stock-movements", format, export)
 }
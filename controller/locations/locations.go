@@ -0,0 +1,129 @@
+package locations
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/loyalsfc/investrite/data"
+	"github.com/loyalsfc/investrite/models"
+	"github.com/loyalsfc/investrite/response"
+	"github.com/loyalsfc/investrite/utils"
+)
+
+type LocationHandler struct {
+	LocationService models.LocationService
+}
+
+func (l LocationHandler) NewLocation(ctx *gin.Context, userId uuid.UUID) {
+	var params data.LocationParams
+	ctx.Bind(&params)
+
+	location, err := l.LocationService.CreateLocation(params)
+
+	if err != nil {
+		response.Error(ctx, 403, fmt.Sprintf("%v", err))
+		return
+	}
+
+	utils.SetAuditEntry(ctx, utils.AuditEntry{EntityID: location.ID, After: location})
+	response.Success(ctx, "location added successfully", location)
+}
+
+func (l LocationHandler) GetLocations(ctx *gin.Context, userId uuid.UUID) {
+	locations, err := l.LocationService.GetLocations()
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	response.Success(ctx, "locations retrieved successfully", locations)
+}
+
+func (l LocationHandler) GetLocation(ctx *gin.Context, userId uuid.UUID) {
+	id, err := utils.GetIDInRoute(ctx, "locationID")
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	location, err := l.LocationService.GetLocationById(id)
+
+	if err != nil {
+		response.Error(ctx, 404, fmt.Sprintf("%v", err))
+		return
+	}
+
+	response.Success(ctx, "location retrieved successfully", location)
+}
+
+func (l LocationHandler) UpdateLocation(ctx *gin.Context, userId uuid.UUID) {
+	var params data.LocationParams
+	ctx.Bind(&params)
+
+	id, err := utils.GetIDInRoute(ctx, "locationID")
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	if err := l.LocationService.UpdateLocation(id, params); err != nil {
+		response.Error(ctx, 404, fmt.Sprintf("%v", err))
+		return
+	}
+
+	response.Success(ctx, "location updated successfully", nil)
+}
+
+func (l LocationHandler) SetDefaultLocation(ctx *gin.Context, userId uuid.UUID) {
+	id, err := utils.GetIDInRoute(ctx, "locationID")
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	if err := l.LocationService.SetDefaultLocation(id); err != nil {
+		response.Error(ctx, 404, fmt.Sprintf("%v", err))
+		return
+	}
+
+	response.Success(ctx, "default location updated", nil)
+}
+
+func (l LocationHandler) DeleteLocation(ctx *gin.Context, userId uuid.UUID) {
+	id, err := utils.GetIDInRoute(ctx, "locationID")
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	if err := l.LocationService.DeleteLocation(id); err != nil {
+		response.Error(ctx, 403, fmt.Sprintf("%v", err))
+		return
+	}
+
+	response.Success(ctx, "location deleted successfully", nil)
+}
+
+func (l LocationHandler) GetLocationStock(ctx *gin.Context, userId uuid.UUID) {
+	id, err := utils.GetIDInRoute(ctx, "locationID")
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	stock, err := l.LocationService.GetLocationStock(id)
+
+	if err != nil {
+		response.Error(ctx, 404, fmt.Sprintf("%v", err))
+		return
+	}
+
+	response.Success(ctx, "location stock retrieved successfully", stock)
+}
@@ -1,14 +1,12 @@
 package orders
 
 import (
-	"fmt"
-
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"github.com/loyalsfc/investrite/controller/role"
 	"github.com/loyalsfc/investrite/data"
 	"github.com/loyalsfc/investrite/models"
 	"github.com/loyalsfc/investrite/response"
+	"github.com/loyalsfc/investrite/spreadsheet"
 	"github.com/loyalsfc/investrite/utils"
 )
 
@@ -20,105 +18,126 @@ func (o OrderHandler) NewOrder(ctx *gin.Context, userId uuid.UUID) {
 	var params data.OrderParams
 	ctx.Bind(&params)
 
-	userRole, err := role.GetUserRole(userId)
-
-	if err != nil {
-		response.Error(ctx, 403, fmt.Sprintf("%v", err))
-		return
-	}
-
-	if utils.RoleLevel(userRole) < 2 {
-		response.PermissionError(ctx)
-		return
-	}
-
-	order, err := o.OrderService.CreateOrder(params)
+	order, err := o.OrderService.CreateOrder(params, userId)
 
 	if err != nil {
 		response.Error(ctx, 403, err.Error())
 		return
 	}
 
+	utils.SetAuditEntry(ctx, utils.AuditEntry{EntityID: order.OrderID, After: order})
 	response.Success(ctx, "order created successfully", order)
 }
 
 func (o OrderHandler) GetOrders(ctx *gin.Context, userId uuid.UUID) {
-	userRole, err := role.GetUserRole(userId)
+	var filter data.OrderFilter
 
-	if err != nil {
-		response.Error(ctx, 403, fmt.Sprintf("%v", err))
+	if err := ctx.ShouldBindQuery(&filter); err != nil {
+		response.Error(ctx, 400, err.Error())
 		return
 	}
 
-	if utils.RoleLevel(userRole) < 2 {
-		response.PermissionError(ctx)
+	var err error
+	filter.LocationID, err = utils.GetOptionalIDInQuery(ctx, "location_id")
+
+	if err != nil {
+		response.Error(ctx, 400, err.Error())
 		return
 	}
 
-	orders, err := o.OrderService.GetAllOrders()
+	orders, meta, err := o.OrderService.GetAllOrders(filter)
 
 	if err != nil {
 		response.Error(ctx, 400, err.Error())
 		return
 	}
 
-	response.Success(ctx, "orders retrieved successfully", orders)
+	response.SuccessWithMeta(ctx, "orders retrieved successfully", orders, meta)
 }
 
 func (o OrderHandler) GetOrder(ctx *gin.Context, userId uuid.UUID) {
-	userRole, err := role.GetUserRole(userId)
+	id, err := utils.GetIDInRoute(ctx, "orderId")
 
 	if err != nil {
-		response.Error(ctx, 403, fmt.Sprintf("%v", err))
+		response.Error(ctx, 401, err.Error())
 		return
 	}
+	order, err := o.OrderService.FindOrder(id)
 
-	if utils.RoleLevel(userRole) < 2 {
-		response.PermissionError(ctx)
+	if err != nil {
+		response.Error(ctx, 401, err.Error())
 		return
 	}
 
+	response.Success(ctx, "order retrieved successfully", order)
+}
+
+func (o OrderHandler) DeleteOrder(ctx *gin.Context, userId uuid.UUID) {
 	id, err := utils.GetIDInRoute(ctx, "orderId")
 
 	if err != nil {
 		response.Error(ctx, 401, err.Error())
 		return
 	}
-	order, err := o.OrderService.FindOrder(id)
+	deleteErr := o.OrderService.DeleteOrder(id)
 
-	if err != nil {
-		response.Error(ctx, 401, err.Error())
+	if deleteErr != nil {
+		response.Error(ctx, 401, deleteErr.Error())
 		return
 	}
 
-	response.Success(ctx, "order retrieved successfully", order)
+	response.Success(ctx, "order deleted successfully", nil)
 }
 
-func (o OrderHandler) DeleteOrder(ctx *gin.Context, userId uuid.UUID) {
-	userRole, err := role.GetUserRole(userId)
+func (o OrderHandler) RefundOrder(ctx *gin.Context, userId uuid.UUID) {
+	var params data.RefundParams
+	ctx.Bind(&params)
+
+	id, err := utils.GetIDInRoute(ctx, "orderId")
 
 	if err != nil {
-		response.Error(ctx, 403, fmt.Sprintf("%v", err))
+		response.Error(ctx, 401, err.Error())
 		return
 	}
 
-	if utils.RoleLevel(userRole) < 4 {
-		response.PermissionError(ctx)
+	refund, err := o.OrderService.RefundOrder(id, params, userId)
+
+	if err != nil {
+		response.Error(ctx, 403, err.Error())
 		return
 	}
 
-	id, err := utils.GetIDInRoute(ctx, "orderId")
+	response.Success(ctx, "order refunded successfully", refund)
+}
+
+func (o OrderHandler) ExportOrders(ctx *gin.Context, userId uuid.UUID) {
+	format := ctx.DefaultQuery("format", "csv")
+	if !spreadsheet.IsValidFormat(format) {
+		response.Error(ctx, 400, "format must be csv, xlsx or ndjson")
+		return
+	}
+
+	var filter data.OrderFilter
+
+	if err := ctx.ShouldBindQuery(&filter); err != nil {
+		response.Error(ctx, 400, err.Error())
+		return
+	}
+
+	var err error
+	filter.LocationID, err = utils.GetOptionalIDInQuery(ctx, "location_id")
 
 	if err != nil {
-		response.Error(ctx, 401, err.Error())
+		response.Error(ctx, 400, err.Error())
 		return
 	}
-	deleteErr := o.OrderService.DeleteOrder(id)
 
-	if deleteErr != nil {
-		response.Error(ctx, 401, deleteErr.Error())
+	export, err := o.OrderService.ExportOrders(filter)
+
+	if err != nil {
+		response.Error(ctx, 400, err.Error())
 		return
 	}
 
-	response.Success(ctx, "order deleted successfully", nil)
+	response.Export(ctx, "orders", format, export)
 }
@@ -0,0 +1,51 @@
+package reports
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/loyalsfc/investrite/data"
+	"github.com/loyalsfc/investrite/models"
+	"github.com/loyalsfc/investrite/response"
+)
+
+type ReportHandler struct {
+	ReportService models.ReportService
+}
+
+func (r ReportHandler) GetSalesReport(ctx *gin.Context, userId uuid.UUID) {
+	var params data.SalesReportParams
+
+	if err := ctx.ShouldBindQuery(&params); err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	report, err := r.ReportService.SalesSummary(params)
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	response.Success(ctx, "sales report retrieved successfully", report)
+}
+
+func (r ReportHandler) GetInventoryValuation(ctx *gin.Context, userId uuid.UUID) {
+	var params data.ValuationParams
+
+	if err := ctx.ShouldBindQuery(&params); err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	valuation, err := r.ReportService.InventoryValuation(params)
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	response.Success(ctx, "inventory valuation retrieved successfully", valuation)
+}
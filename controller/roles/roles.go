@@ -0,0 +1,111 @@
+package roles
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/loyalsfc/investrite/data"
+	"github.com/loyalsfc/investrite/models"
+	"github.com/loyalsfc/investrite/response"
+	"github.com/loyalsfc/investrite/utils"
+)
+
+type RoleHandler struct {
+	RoleService models.RoleService
+}
+
+func (r RoleHandler) GetPermissions(ctx *gin.Context, userId uuid.UUID) {
+	response.Success(ctx, "permissions retrieved successfully", utils.AllPermissions())
+}
+
+func (r RoleHandler) GetRoles(ctx *gin.Context, userId uuid.UUID) {
+	roles, err := r.RoleService.GetRoles()
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	response.Success(ctx, "roles retrieved successfully", roles)
+}
+
+func (r RoleHandler) NewRole(ctx *gin.Context, userId uuid.UUID) {
+	var params data.RoleParams
+	ctx.Bind(&params)
+
+	//Roles can only be built from permissions the creator holds
+	if !utils.HasPermissions(ctx, params.Permissions) {
+		response.PermissionError(ctx)
+		return
+	}
+
+	role, err := r.RoleService.CreateRole(params)
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	utils.SetAuditEntry(ctx, utils.AuditEntry{EntityID: role.ID, After: role})
+	response.Success(ctx, "role created successfully", role)
+}
+
+func (r RoleHandler) UpdateRole(ctx *gin.Context, userId uuid.UUID) {
+	var params data.RoleParams
+	ctx.Bind(&params)
+
+	id, err := utils.GetIDInRoute(ctx, "roleID")
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	role, err := r.RoleService.GetRole(id)
+
+	if err != nil {
+		response.Error(ctx, 404, fmt.Sprintf("%v", err))
+		return
+	}
+
+	if !utils.HasPermissions(ctx, role.PermissionList()) || !utils.HasPermissions(ctx, params.Permissions) {
+		response.PermissionError(ctx)
+		return
+	}
+
+	if err := r.RoleService.UpdateRole(id, params); err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	response.Success(ctx, "role updated successfully", nil)
+}
+
+func (r RoleHandler) DeleteRole(ctx *gin.Context, userId uuid.UUID) {
+	id, err := utils.GetIDInRoute(ctx, "roleID")
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	role, err := r.RoleService.GetRole(id)
+
+	if err != nil {
+		response.Error(ctx, 404, fmt.Sprintf("%v", err))
+		return
+	}
+
+	if !utils.HasPermissions(ctx, role.PermissionList()) {
+		response.PermissionError(ctx)
+		return
+	}
+
+	if err := r.RoleService.DeleteRole(id); err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	response.Success(ctx, "role deleted successfully", nil)
+}
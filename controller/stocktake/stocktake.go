@@ -0,0 +1,131 @@
+package stocktake
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/loyalsfc/investrite/data"
+	"github.com/loyalsfc/investrite/models"
+	"github.com/loyalsfc/investrite/response"
+	"github.com/loyalsfc/investrite/utils"
+)
+
+type StocktakeHandler struct {
+	StocktakeService models.StocktakeService
+}
+
+func (s StocktakeHandler) OpenStocktake(ctx *gin.Context, userId uuid.UUID) {
+	var params data.OpenStocktakeParams
+	ctx.Bind(&params)
+
+	stocktake, err := s.StocktakeService.OpenStocktake(params, userId)
+
+	if err != nil {
+		response.Error(ctx, 403, fmt.Sprintf("%v", err))
+		return
+	}
+
+	utils.SetAuditEntry(ctx, utils.AuditEntry{EntityID: stocktake.ID, After: stocktake})
+	response.Success(ctx, "stocktake opened", stocktake)
+}
+
+func (s StocktakeHandler) GetStocktakes(ctx *gin.Context, userId uuid.UUID) {
+	stocktakes, err := s.StocktakeService.GetStocktakes()
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	response.Success(ctx, "stocktakes retrieved successfully", stocktakes)
+}
+
+func (s StocktakeHandler) GetStocktake(ctx *gin.Context, userId uuid.UUID) {
+	id, err := utils.GetIDInRoute(ctx, "stocktakeID")
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	stocktake, err := s.StocktakeService.GetStocktake(id)
+
+	if err != nil {
+		response.Error(ctx, 404, fmt.Sprintf("%v", err))
+		return
+	}
+
+	response.Success(ctx, "stocktake retrieved successfully", stocktake)
+}
+
+func (s StocktakeHandler) SubmitCounts(ctx *gin.Context, userId uuid.UUID) {
+	var params data.SubmitCountsParams
+	ctx.Bind(&params)
+
+	id, err := utils.GetIDInRoute(ctx, "stocktakeID")
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	if err := s.StocktakeService.SubmitCounts(id, params, userId); err != nil {
+		response.Error(ctx, 403, fmt.Sprintf("%v", err))
+		return
+	}
+
+	response.Success(ctx, "counts submitted", nil)
+}
+
+func (s StocktakeHandler) CloseStocktake(ctx *gin.Context, userId uuid.UUID) {
+	id, err := utils.GetIDInRoute(ctx, "stocktakeID")
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	stocktake, err := s.StocktakeService.CloseStocktake(id, userId)
+
+	if err != nil {
+		response.Error(ctx, 403, fmt.Sprintf("%v", err))
+		return
+	}
+
+	response.Success(ctx, "stocktake closed", stocktake)
+}
+
+func (s StocktakeHandler) ApproveStocktake(ctx *gin.Context, userId uuid.UUID) {
+	id, err := utils.GetIDInRoute(ctx, "stocktakeID")
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	stocktake, err := s.StocktakeService.ApproveStocktake(id, userId)
+
+	if err != nil {
+		response.Error(ctx, 403, fmt.Sprintf("%v", err))
+		return
+	}
+
+	response.Success(ctx, "stocktake approved", stocktake)
+}
+
+func (s StocktakeHandler) CancelStocktake(ctx *gin.Context, userId uuid.UUID) {
+	id, err := utils.GetIDInRoute(ctx, "stocktakeID")
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	if err := s.StocktakeService.CancelStocktake(id); err != nil {
+		response.Error(ctx, 403, fmt.Sprintf("%v", err))
+		return
+	}
+
+	response.Success(ctx, "stocktake cancelled", nil)
+}
@@ -0,0 +1,212 @@
+package suppliers
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/loyalsfc/investrite/data"
+	"github.com/loyalsfc/investrite/models"
+	"github.com/loyalsfc/investrite/response"
+	"github.com/loyalsfc/investrite/utils"
+)
+
+type SupplierHandler struct {
+	SupplierService      models.SupplierService
+	PurchaseOrderService models.PurchaseOrderService
+}
+
+func (s SupplierHandler) NewSupplier(ctx *gin.Context, userId uuid.UUID) {
+	var params data.SupplierParams
+	ctx.Bind(&params)
+
+	supplier, err := s.SupplierService.CreateSupplier(params)
+
+	if err != nil {
+		response.Error(ctx, 403, fmt.Sprintf("%v", err))
+		return
+	}
+
+	utils.SetAuditEntry(ctx, utils.AuditEntry{EntityID: supplier.ID, After: supplier})
+	response.Success(ctx, "supplier added successfully", supplier)
+}
+
+func (s SupplierHandler) GetSuppliers(ctx *gin.Context, userId uuid.UUID) {
+	suppliers, err := s.SupplierService.GetSuppliers()
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	response.Success(ctx, "suppliers retrieved successfully", suppliers)
+}
+
+func (s SupplierHandler) GetSupplier(ctx *gin.Context, userId uuid.UUID) {
+	id, err := utils.GetIDInRoute(ctx, "supplierID")
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	supplier, err := s.SupplierService.GetSupplierById(id)
+
+	if err != nil {
+		response.Error(ctx, 404, fmt.Sprintf("%v", err))
+		return
+	}
+
+	response.Success(ctx, "supplier retrieved successfully", supplier)
+}
+
+func (s SupplierHandler) UpdateSupplier(ctx *gin.Context, userId uuid.UUID) {
+	var params data.SupplierParams
+	ctx.Bind(&params)
+
+	id, err := utils.GetIDInRoute(ctx, "supplierID")
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	if err := s.SupplierService.UpdateSupplier(id, params); err != nil {
+		response.Error(ctx, 404, fmt.Sprintf("%v", err))
+		return
+	}
+
+	response.Success(ctx, "supplier updated successfully", nil)
+}
+
+func (s SupplierHandler) DeleteSupplier(ctx *gin.Context, userId uuid.UUID) {
+	id, err := utils.GetIDInRoute(ctx, "supplierID")
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	if err := s.SupplierService.DeleteSupplier(id); err != nil {
+		response.Error(ctx, 403, fmt.Sprintf("%v", err))
+		return
+	}
+
+	response.Success(ctx, "supplier deleted successfully", nil)
+}
+
+func (s SupplierHandler) NewPurchaseOrder(ctx *gin.Context, userId uuid.UUID) {
+	var params data.PurchaseOrderParams
+	ctx.Bind(&params)
+
+	order, err := s.PurchaseOrderService.CreatePurchaseOrder(params, userId)
+
+	if err != nil {
+		response.Error(ctx, 403, fmt.Sprintf("%v", err))
+		return
+	}
+
+	utils.SetAuditEntry(ctx, utils.AuditEntry{EntityID: order.ID, After: order})
+	response.Success(ctx, "purchase order created successfully", order)
+}
+
+func (s SupplierHandler) GetPurchaseOrders(ctx *gin.Context, userId uuid.UUID) {
+	orders, err := s.PurchaseOrderService.GetPurchaseOrders()
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	response.Success(ctx, "purchase orders retrieved successfully", orders)
+}
+
+func (s SupplierHandler) GetPurchaseOrder(ctx *gin.Context, userId uuid.UUID) {
+	id, err := utils.GetIDInRoute(ctx, "purchaseOrderID")
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	order, err := s.PurchaseOrderService.GetPurchaseOrder(id)
+
+	if err != nil {
+		response.Error(ctx, 404, fmt.Sprintf("%v", err))
+		return
+	}
+
+	response.Success(ctx, "purchase order retrieved successfully", order)
+}
+
+func (s SupplierHandler) UpdatePurchaseOrder(ctx *gin.Context, userId uuid.UUID) {
+	var params data.PurchaseOrderParams
+	ctx.Bind(&params)
+
+	id, err := utils.GetIDInRoute(ctx, "purchaseOrderID")
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	if err := s.PurchaseOrderService.UpdatePurchaseOrder(id, params); err != nil {
+		response.Error(ctx, 403, fmt.Sprintf("%v", err))
+		return
+	}
+
+	response.Success(ctx, "purchase order updated successfully", nil)
+}
+
+func (s SupplierHandler) SubmitPurchaseOrder(ctx *gin.Context, userId uuid.UUID) {
+	id, err := utils.GetIDInRoute(ctx, "purchaseOrderID")
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	if err := s.PurchaseOrderService.SubmitPurchaseOrder(id); err != nil {
+		response.Error(ctx, 403, fmt.Sprintf("%v", err))
+		return
+	}
+
+	response.Success(ctx, "purchase order submitted", nil)
+}
+
+func (s SupplierHandler) CancelPurchaseOrder(ctx *gin.Context, userId uuid.UUID) {
+	id, err := utils.GetIDInRoute(ctx, "purchaseOrderID")
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	if err := s.PurchaseOrderService.CancelPurchaseOrder(id); err != nil {
+		response.Error(ctx, 403, fmt.Sprintf("%v", err))
+		return
+	}
+
+	response.Success(ctx, "purchase order cancelled", nil)
+}
+
+func (s SupplierHandler) ReceivePurchaseOrder(ctx *gin.Context, userId uuid.UUID) {
+	var params data.ReceivePurchaseOrderParams
+	ctx.Bind(&params)
+
+	id, err := utils.GetIDInRoute(ctx, "purchaseOrderID")
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	order, err := s.PurchaseOrderService.ReceivePurchaseOrder(id, params, userId)
+
+	if err != nil {
+		response.Error(ctx, 403, fmt.Sprintf("%v", err))
+		return
+	}
+
+	response.Success(ctx, "purchase order received", order)
+}
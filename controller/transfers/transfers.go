@@ -0,0 +1,115 @@
+package transfers
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/loyalsfc/investrite/data"
+	"github.com/loyalsfc/investrite/models"
+	"github.com/loyalsfc/investrite/response"
+	"github.com/loyalsfc/investrite/utils"
+)
+
+type TransferHandler struct {
+	TransferService models.TransferService
+}
+
+func (t TransferHandler) RequestTransfer(ctx *gin.Context, userId uuid.UUID) {
+	var params data.TransferParams
+	ctx.Bind(&params)
+
+	transfer, err := t.TransferService.RequestTransfer(params, userId)
+
+	if err != nil {
+		response.Error(ctx, 403, fmt.Sprintf("%v", err))
+		return
+	}
+
+	utils.SetAuditEntry(ctx, utils.AuditEntry{EntityID: transfer.ID, After: transfer})
+	response.Success(ctx, "transfer requested", transfer)
+}
+
+func (t TransferHandler) GetTransfers(ctx *gin.Context, userId uuid.UUID) {
+	transfers, err := t.TransferService.GetTransfers()
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	response.Success(ctx, "transfers retrieved successfully", transfers)
+}
+
+func (t TransferHandler) GetTransfer(ctx *gin.Context, userId uuid.UUID) {
+	id, err := utils.GetIDInRoute(ctx, "transferID")
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	transfer, err := t.TransferService.GetTransfer(id)
+
+	if err != nil {
+		response.Error(ctx, 404, fmt.Sprintf("%v", err))
+		return
+	}
+
+	response.Success(ctx, "transfer retrieved successfully", transfer)
+}
+
+func (t TransferHandler) DispatchTransfer(ctx *gin.Context, userId uuid.UUID) {
+	id, err := utils.GetIDInRoute(ctx, "transferID")
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	transfer, err := t.TransferService.DispatchTransfer(id, userId)
+
+	if err != nil {
+		response.Error(ctx, 403, fmt.Sprintf("%v", err))
+		return
+	}
+
+	response.Success(ctx, "transfer dispatched", transfer)
+}
+
+func (t TransferHandler) ReceiveTransfer(ctx *gin.Context, userId uuid.UUID) {
+	var params data.ReceiveTransferParams
+	ctx.Bind(&params)
+
+	id, err := utils.GetIDInRoute(ctx, "transferID")
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	transfer, err := t.TransferService.ReceiveTransfer(id, params, userId)
+
+	if err != nil {
+		response.Error(ctx, 403, fmt.Sprintf("%v", err))
+		return
+	}
+
+	response.Success(ctx, "transfer received", transfer)
+}
+
+func (t TransferHandler) CancelTransfer(ctx *gin.Context, userId uuid.UUID) {
+	id, err := utils.GetIDInRoute(ctx, "transferID")
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	if err := t.TransferService.CancelTransfer(id); err != nil {
+		response.Error(ctx, 403, fmt.Sprintf("%v", err))
+		return
+	}
+
+	response.Success(ctx, "transfer cancelled", nil)
+}
@@ -6,24 +6,70 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/loyalsfc/investrite/data"
+	"github.com/loyalsfc/investrite/mailer"
 	"github.com/loyalsfc/investrite/models"
 	"github.com/loyalsfc/investrite/response"
+	"github.com/loyalsfc/investrite/spreadsheet"
 	"github.com/loyalsfc/investrite/utils"
 )
 
 type UserHandler struct {
 	UserService models.UserService
+	RoleService models.RoleService
+	Mailer      mailer.Sender
 }
 
 func (u UserHandler) GetAllUsers(ctx *gin.Context, userId uuid.UUID) {
-	users, err := u.UserService.GetUsers()
+	var filter data.UserFilter
+
+	if err := ctx.ShouldBindQuery(&filter); err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("err %v", err))
+		return
+	}
+
+	users, meta, err := u.UserService.GetUsers(filter)
 
 	if err != nil {
-		response.Error(ctx, 401, fmt.Sprintf("err %v", err))
+		response.Error(ctx, 400, fmt.Sprintf("err %v", err))
 		return
 	}
 
-	response.Success(ctx, "users retried successfully", users)
+	response.SuccessWithMeta(ctx, "users retried successfully", users, meta)
+}
+
+// canAssign stops users who manage accounts from handing out, or taking
+// away, more access than they hold themselves. Only admins may grant the
+// admin role or change an admin's account.
+func (u UserHandler) canAssign(ctx *gin.Context, userId uuid.UUID, role utils.UserRole) bool {
+	if role == utils.AdminRole {
+		userRole, err := u.UserService.GetUserRole(userId)
+
+		if err != nil {
+			response.Error(ctx, 404, fmt.Sprintf("%v", err))
+			return false
+		}
+
+		if userRole != utils.AdminRole {
+			response.PermissionError(ctx)
+			return false
+		}
+
+		return true
+	}
+
+	permissions, err := u.RoleService.RolePermissions(string(role))
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return false
+	}
+
+	if !utils.HasPermissions(ctx, permissions) {
+		response.PermissionError(ctx)
+		return false
+	}
+
+	return true
 }
 
 func (u UserHandler) UpdateRole(ctx *gin.Context, userId uuid.UUID) {
@@ -31,15 +77,18 @@ func (u UserHandler) UpdateRole(ctx *gin.Context, userId uuid.UUID) {
 
 	ctx.Bind(&data)
 
-	userRole, err := u.UserService.GetUserRole(userId)
+	if !u.canAssign(ctx, userId, data.Role) {
+		return
+	}
+
+	changedUser, err := u.UserService.GetUser(data.Email)
 
 	if err != nil {
 		response.Error(ctx, 404, fmt.Sprintf("%v", err))
 		return
 	}
 
-	if userRole != utils.AdminRole {
-		response.PermissionError(ctx)
+	if !u.canAssign(ctx, userId, utils.UserRole(changedUser.Role)) {
 		return
 	}
 
@@ -48,6 +97,7 @@ func (u UserHandler) UpdateRole(ctx *gin.Context, userId uuid.UUID) {
 		return
 	}
 
+	utils.SetAuditEntry(ctx, utils.AuditEntry{EntityID: changedUser.UserID, Before: changedUser})
 	response.Success(ctx, "user role updated successfully", nil)
 }
 
@@ -59,22 +109,111 @@ func (u UserHandler) DeleteUser(ctx *gin.Context, userId uuid.UUID) {
 		return
 	}
 
-	userRole, err := u.UserService.GetUserRole(userId)
+	if id != userId {
+		if !utils.HasPermission(ctx, utils.PermUserManage) {
+			response.PermissionError(ctx)
+			return
+		}
 
-	if err != nil {
+		deletedUser, err := u.UserService.GetUserById(id)
+
+		if err != nil {
+			response.Error(ctx, 404, fmt.Sprintf("%v", err))
+			return
+		}
+
+		if !u.canAssign(ctx, userId, utils.UserRole(deletedUser.Role)) {
+			return
+		}
+	}
+
+	if err := u.UserService.DeleteUser(id); err != nil {
 		response.Error(ctx, 404, fmt.Sprintf("%v", err))
 		return
 	}
 
-	if id != userId && userRole != utils.AdminRole {
-		response.PermissionError(ctx)
+	response.Success(ctx, "user deleted successfully", nil)
+}
+
+func (u UserHandler) ExportUsers(ctx *gin.Context, userId uuid.UUID) {
+	format := ctx.DefaultQuery("format", "csv")
+	if !spreadsheet.IsValidFormat(format) {
+		response.Error(ctx, 400, "format must be csv, xlsx or ndjson")
 		return
 	}
 
-	if err := u.UserService.DeleteUser(id); err != nil {
+	var filter data.UserFilter
+
+	if err := ctx.ShouldBindQuery(&filter); err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("err %v", err))
+		return
+	}
+
+	export, err := u.UserService.ExportUsers(filter)
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("err %v", err))
+		return
+	}
+
+	response.Export(ctx, "users", format, export)
+}
+
+func (u UserHandler) InviteUser(ctx *gin.Context, userId uuid.UUID) {
+	var params data.InviteUserParams
+	ctx.Bind(&params)
+
+	if !u.canAssign(ctx, userId, params.Role) {
+		return
+	}
+
+	invitation, token, err := u.UserService.CreateInvitation(params, userId)
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	//Sent before responding so the admin learns when the mail did not go out
+	err = u.Mailer.Send(mailer.Message{
+		To:      invitation.Email,
+		Subject: "You have been invited to Investrite",
+		Body: fmt.Sprintf("You have been invited to join as %v. Set your password with this link within 7 days:\n\n%v\n",
+			invitation.Role, mailer.AppLink("/accept-invite", token)),
+	})
+
+	if err != nil {
+		response.Error(ctx, 502, fmt.Sprintf("invitation saved but the email could not be sent: %v", err))
+		return
+	}
+
+	utils.SetAuditEntry(ctx, utils.AuditEntry{EntityID: invitation.ID, After: invitation})
+	response.Success(ctx, "invitation sent", invitation)
+}
+
+func (u UserHandler) GetInvitations(ctx *gin.Context, userId uuid.UUID) {
+	invitations, err := u.UserService.GetInvitations(ctx.Query("status") == "pending")
+
+	if err != nil {
+		response.Error(ctx, 400, fmt.Sprintf("%v", err))
+		return
+	}
+
+	response.Success(ctx, "invitations retrieved successfully", invitations)
+}
+
+func (u UserHandler) RevokeInvitation(ctx *gin.Context, userId uuid.UUID) {
+	id, err := utils.GetIDInRoute(ctx, "invitationID")
+
+	if err != nil {
+		response.Error(ctx, 400, "bad request")
+		return
+	}
+
+	if err := u.UserService.RevokeInvitation(id); err != nil {
 		response.Error(ctx, 404, fmt.Sprintf("%v", err))
 		return
 	}
 
-	response.Success(ctx, "user deleted successfully", nil)
+	response.Success(ctx, "invitation revoked", nil)
 }
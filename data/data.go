@@ -1,17 +1,58 @@
 package data
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 	"github.com/loyalsfc/investrite/utils"
 )
 
 type AddProductParams struct {
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	Quantity    int       `json:"quantity"`
-	Price       int       `json:"price"`
-	Image       string    `json:"image"`
-	CategoryId  uuid.UUID `json:"category_id"`
+	Name            string    `json:"name"`
+	Description     string    `json:"description"`
+	Quantity        int       `json:"quantity"`
+	Price           int       `json:"price"`
+	CostPrice       int       `json:"cost_price"`
+	Image           string    `json:"image"`
+	CategoryId      uuid.UUID `json:"category_id"`
+	ReorderPoint    int       `json:"reorder_point"`
+	ReorderQuantity int       `json:"reorder_quantity"`
+	LocationID      uuid.UUID `json:"location_id"`
+	SKU             string    `json:"sku"`
+	Barcode         string    `json:"barcode"`
+}
+
+type ProductOptionParams struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+type SetProductOptionsParams struct {
+	Options []ProductOptionParams `json:"options"`
+}
+
+type VariantParams struct {
+	SKU        string            `json:"sku"`
+	Barcode    string            `json:"barcode"`
+	Options    map[string]string `json:"options"`
+	Price      *int              `json:"price"`
+	Quantity   int               `json:"quantity"`
+	LocationID uuid.UUID         `json:"location_id"`
+}
+
+type LabelItemParams struct {
+	ProductID uuid.UUID `json:"product_id"`
+	VariantID uuid.UUID `json:"variant_id"`
+	Copies    int       `json:"copies"`
+}
+
+type LabelParams struct {
+	Items     []LabelItemParams `json:"items"`
+	Format    string            `json:"format"`
+	Symbology string            `json:"symbology"`
+	WidthMM   float64           `json:"width_mm"`
+	HeightMM  float64           `json:"height_mm"`
+	DPI       int               `json:"dpi"`
 }
 
 type PaymentMethod struct {
@@ -22,12 +63,119 @@ type PaymentMethod struct {
 
 type OrderProducts struct {
 	ProductID uuid.UUID `json:"product_id"`
+	VariantID uuid.UUID `json:"variant_id"`
+	Code      string    `json:"code"`
 	Quantity  int       `json:"quantity"`
 }
 
 type OrderParams struct {
 	Products      []OrderProducts `json:"products"`
 	PaymentMethod PaymentMethod   `json:"payment_method"`
+	LocationID    uuid.UUID       `json:"location_id"`
+}
+
+type RefundItemParams struct {
+	OrderItemID uuid.UUID `json:"order_item_id"`
+	Quantity    int       `json:"quantity"`
+}
+
+type RefundParams struct {
+	Items         []RefundItemParams `json:"items"`
+	PaymentMethod PaymentMethod      `json:"payment_method"`
+	Restock       bool               `json:"restock"`
+	Reason        string             `json:"reason"`
+}
+
+type SupplierParams struct {
+	Name        string `json:"name"`
+	ContactName string `json:"contact_name"`
+	Email       string `json:"email"`
+	Phone       string `json:"phone"`
+	Address     string `json:"address"`
+}
+
+type PurchaseOrderLineParams struct {
+	ProductID uuid.UUID `json:"product_id"`
+	VariantID uuid.UUID `json:"variant_id"`
+	Quantity  int       `json:"quantity"`
+	UnitCost  int       `json:"unit_cost"`
+}
+
+type PurchaseOrderParams struct {
+	SupplierID uuid.UUID                 `json:"supplier_id"`
+	Reference  string                    `json:"reference"`
+	Note       string                    `json:"note"`
+	Lines      []PurchaseOrderLineParams `json:"lines"`
+}
+
+type ReceiveLineParams struct {
+	LineID   uuid.UUID `json:"line_id"`
+	Quantity int       `json:"quantity"`
+	UnitCost int       `json:"unit_cost"`
+}
+
+type ReceivePurchaseOrderParams struct {
+	LocationID uuid.UUID           `json:"location_id"`
+	Lines      []ReceiveLineParams `json:"lines"`
+}
+
+type QuantityAdjustment struct {
+	ProductID     uuid.UUID `json:"product_id"`
+	VariantID     uuid.UUID `json:"variant_id"`
+	LocationID    uuid.UUID `json:"location_id"`
+	Delta         *int      `json:"delta"`
+	AbsoluteCount *int      `json:"absolute_count"`
+	Reason        string    `json:"reason"`
+	Note          string    `json:"note"`
+}
+
+type AdjustQuantityParams struct {
+	Adjustments []QuantityAdjustment `json:"adjustments"`
+}
+
+type OpenStocktakeParams struct {
+	CategoryID *uuid.UUID `json:"category_id"`
+	LocationID uuid.UUID  `json:"location_id"`
+	Note       string     `json:"note"`
+}
+
+type StocktakeCountParams struct {
+	ProductID uuid.UUID `json:"product_id"`
+	VariantID uuid.UUID `json:"variant_id"`
+	Quantity  int       `json:"quantity"`
+}
+
+type SubmitCountsParams struct {
+	Device string                 `json:"device"`
+	Counts []StocktakeCountParams `json:"counts"`
+}
+
+type LocationParams struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+}
+
+type TransferLineParams struct {
+	ProductID uuid.UUID `json:"product_id"`
+	VariantID uuid.UUID `json:"variant_id"`
+	Quantity  int       `json:"quantity"`
+}
+
+type TransferParams struct {
+	SourceLocationID      uuid.UUID            `json:"source_location_id"`
+	DestinationLocationID uuid.UUID            `json:"destination_location_id"`
+	Note                  string               `json:"note"`
+	Lines                 []TransferLineParams `json:"lines"`
+}
+
+type ReceiveTransferLineParams struct {
+	LineID   uuid.UUID `json:"line_id"`
+	Quantity int       `json:"quantity"`
+	Note     string    `json:"note"`
+}
+
+type ReceiveTransferParams struct {
+	Lines []ReceiveTransferLineParams `json:"lines"`
 }
 
 type FormData struct {
@@ -41,3 +189,107 @@ type UpdateUserParams struct {
 	Email string         `json:"email"`
 	Role  utils.UserRole `json:"role"`
 }
+
+type ListParams struct {
+	Page    int    `form:"page"`
+	PerPage int    `form:"per_page"`
+	Sort    string `form:"sort"`
+	Order   string `form:"order"`
+}
+
+type ProductFilter struct {
+	ListParams
+	CategoryID uuid.UUID `form:"-"`
+	MinPrice   *int      `form:"min_price"`
+	MaxPrice   *int      `form:"max_price"`
+	InStock    *bool     `form:"in_stock"`
+}
+
+type OrderFilter struct {
+	ListParams
+	From          time.Time `form:"from" time_format:"2006-01-02"`
+	To            time.Time `form:"to" time_format:"2006-01-02"`
+	Status        string    `form:"status"`
+	PaymentMethod string    `form:"payment_method"`
+	LocationID    uuid.UUID `form:"-"`
+}
+
+type UserFilter struct {
+	ListParams
+	Role string `form:"role"`
+}
+
+type SalesReportParams struct {
+	Period   string    `form:"period"`
+	From     time.Time `form:"from" time_format:"2006-01-02"`
+	To       time.Time `form:"to" time_format:"2006-01-02"`
+	Timezone string    `form:"tz"`
+	Limit    int       `form:"limit"`
+}
+
+type ValuationParams struct {
+	Method   string    `form:"method"`
+	From     time.Time `form:"from" time_format:"2006-01-02"`
+	To       time.Time `form:"to" time_format:"2006-01-02"`
+	Timezone string    `form:"tz"`
+}
+
+type ImportProductsParams struct {
+	DryRun           bool      `form:"dry_run"`
+	CreateCategories bool      `form:"create_categories"`
+	LocationID       uuid.UUID `form:"-"`
+}
+
+type MovementFilter struct {
+	From       time.Time `form:"from" time_format:"2006-01-02"`
+	To         time.Time `form:"to" time_format:"2006-01-02"`
+	Reason     string    `form:"reason"`
+	ProductID  uuid.UUID `form:"-"`
+	LocationID uuid.UUID `form:"-"`
+}
+
+type AuditFilter struct {
+	ListParams
+	From       time.Time `form:"from" time_format:"2006-01-02"`
+	To         time.Time `form:"to" time_format:"2006-01-02"`
+	Action     string    `form:"action"`
+	EntityType string    `form:"entity_type"`
+	EntityID   uuid.UUID `form:"-"`
+	ActorID    uuid.UUID `form:"-"`
+}
+
+type RefreshParams struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type LogoutParams struct {
+	RefreshToken string `json:"refresh_token"`
+	All          bool   `json:"all"`
+}
+
+type ForgotPasswordParams struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordParams struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type InviteUserParams struct {
+	Email string         `json:"email"`
+	Role  utils.UserRole `json:"role"`
+}
+
+type AcceptInviteParams struct {
+	Token     string `json:"token"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Password  string `json:"password"`
+}
+
+type RoleParams struct {
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	Permissions []utils.Permission `json:"permissions"`
+}
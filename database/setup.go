@@ -24,7 +24,35 @@ func InitDB() (*gorm.DB, error) {
 		return nil, err
 	}
 
-	db.AutoMigrate(&models.User{}, &models.Category{}, &models.Product{}, &models.Order{})
+	db.AutoMigrate(&models.User{}, &models.Category{}, &models.Product{}, &models.Order{}, &models.OrderItem{}, &models.Refund{}, &models.RefundItem{}, &models.StockMovement{}, &models.Supplier{}, &models.PurchaseOrder{}, &models.PurchaseOrderLine{}, &models.PurchaseReceipt{}, &models.Stocktake{}, &models.StocktakeCount{}, &models.StocktakeLine{}, &models.StockAlert{}, &models.Location{}, &models.ProductStock{}, &models.StockTransfer{}, &models.TransferLine{}, &models.ProductOption{}, &models.ProductVariant{}, &models.CostLayer{}, &models.AuditEvent{}, &models.RefreshToken{}, &models.RevokedToken{}, &models.AccountToken{}, &models.Invitation{}, &models.Role{})
+
+	if err := models.MigrateOrderItems(db); err != nil {
+		return nil, err
+	}
+
+	if err := models.BackfillOpeningBalances(db); err != nil {
+		return nil, err
+	}
+
+	if err := models.MigrateToLocations(db); err != nil {
+		return nil, err
+	}
+
+	if err := models.MigrateVariants(db); err != nil {
+		return nil, err
+	}
+
+	if err := models.MigrateSearchIndexes(db); err != nil {
+		return nil, err
+	}
+
+	if err := models.MigrateCostLayers(db); err != nil {
+		return nil, err
+	}
+
+	if err := models.MigrateRoles(db); err != nil {
+		return nil, err
+	}
 
 	return db, nil
 }
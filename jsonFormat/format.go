@@ -3,13 +3,13 @@ package jsonformat
 import "github.com/loyalsfc/investrite/models"
 
 type SigninStruct struct {
-	AccessToken string      `json:"access_token"`
-	UserData    models.User `json:"user_data"`
+	models.TokenPair
+	UserData models.User `json:"user_data"`
 }
 
-func SignInToSignIn(user *models.User, token string) SigninStruct {
+func SignInToSignIn(user *models.User, tokens *models.TokenPair) SigninStruct {
 	return SigninStruct{
-		AccessToken: token,
-		UserData:    *user,
+		TokenPair: *tokens,
+		UserData:  *user,
 	}
 }
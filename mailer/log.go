@@ -0,0 +1,38 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// LogSender writes messages to a file, or to the process log when Path is
+// empty, so the flows can be exercised locally without a mail server.
+type LogSender struct {
+	Path string
+}
+
+var logMutex sync.Mutex
+
+func (l LogSender) Send(msg Message) error {
+	entry := fmt.Sprintf("%v\nTo: %v\nSubject: %v\n\n%v\n\n", time.Now().Format(time.RFC3339), msg.To, msg.Subject, msg.Body)
+
+	if l.Path == "" {
+		log.Print("mail " + entry)
+		return nil
+	}
+
+	logMutex.Lock()
+	defer logMutex.Unlock()
+
+	file, err := os.OpenFile(l.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.WriteString(entry)
+	return err
+}
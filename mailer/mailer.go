@@ -0,0 +1,65 @@
+package mailer
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Sender interface {
+	Send(msg Message) error
+}
+
+// FromEnv picks the sender named by MAIL_SENDER:
+//
+//	smtp  SMTP_HOST, SMTP_PORT, SMTP_USERNAME, SMTP_PASSWORD and MAIL_FROM
+//	log   MAIL_LOG_FILE, or the process log when unset (the default)
+func FromEnv() (Sender, error) {
+	switch os.Getenv("MAIL_SENDER") {
+	case "", "log":
+		return LogSender{Path: os.Getenv("MAIL_LOG_FILE")}, nil
+	case "smtp":
+		port := 587
+		if value := os.Getenv("SMTP_PORT"); value != "" {
+			var err error
+			if port, err = strconv.Atoi(value); err != nil {
+				return nil, fmt.Errorf("invalid SMTP_PORT %v", value)
+			}
+		}
+
+		sender := SMTPSender{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("MAIL_FROM"),
+		}
+
+		if sender.Host == "" || sender.From == "" {
+			return nil, fmt.Errorf("SMTP_HOST and MAIL_FROM are required for the smtp sender")
+		}
+
+		return sender, nil
+	default:
+		return nil, fmt.Errorf("unknown MAIL_SENDER %v, use smtp or log", os.Getenv("MAIL_SENDER"))
+	}
+}
+
+// AppLink builds a link carrying token to path on APP_URL, the address users
+// reach the service at.
+func AppLink(path string, token string) string {
+	base := os.Getenv("APP_URL")
+	if base == "" {
+		base = "http://localhost:8080"
+	}
+
+	return strings.TrimRight(base, "/") + path + "?token=" + url.QueryEscape(token)
+}
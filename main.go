@@ -1,12 +1,17 @@
 package main
 
 import (
+	"fmt"
 	"io"
 	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/loyalsfc/investrite/database"
+	"github.com/loyalsfc/investrite/mailer"
+	"github.com/loyalsfc/investrite/models"
 	"github.com/loyalsfc/investrite/routes"
+	"github.com/loyalsfc/investrite/utils"
 )
 
 type Mide struct {
@@ -25,7 +30,19 @@ func main() {
 		panic("failed to connect to database")
 	}
 
-	router := routes.InitRoutes(db)
+	if err := utils.LoadKeyring(); err != nil {
+		panic(fmt.Sprintf("failed to load signing keys: %v", err))
+	}
+
+	mail, err := mailer.FromEnv()
+
+	if err != nil {
+		panic(fmt.Sprintf("failed to configure mail: %v", err))
+	}
+
+	go models.AlertService{DB: db}.Run(5 * time.Minute)
+
+	router := routes.InitRoutes(db, mail)
 
 	router.Run()
 }
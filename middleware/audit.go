@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"log"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/loyalsfc/investrite/models"
+	"github.com/loyalsfc/investrite/utils"
+)
+
+// AuditLoader fetches the current state of an audited entity.
+type AuditLoader func(id uuid.UUID) (interface{}, error)
+
+// Loader adapts a service getter such as ProductService.GetProductById.
+func Loader[T any](get func(id uuid.UUID) (*T, error)) AuditLoader {
+	return func(id uuid.UUID) (interface{}, error) {
+		value, err := get(id)
+		if err != nil {
+			return nil, err
+		}
+
+		return value, nil
+	}
+}
+
+// Audit records an AuditEvent once handler has responded successfully. The
+// entity id is read from the param route segment unless the handler sets an
+// utils.AuditEntry, and load, when given, snapshots the entity before and
+// after the handler runs so the event carries a diff.
+func (m *Middleware) Audit(entityType string, action string, param string, load AuditLoader, handler handlerFunc) handlerFunc {
+	return func(ctx *gin.Context, userId uuid.UUID) {
+		var entityID uuid.UUID
+		if param != "" {
+			entityID, _ = uuid.Parse(ctx.Param(param))
+		}
+
+		//A failed load just means there is nothing to compare against
+		var before interface{}
+		if load != nil && entityID != uuid.Nil {
+			before, _ = load(entityID)
+		}
+
+		handler(ctx, userId)
+
+		if ctx.Writer.Status() >= 300 {
+			return
+		}
+
+		var after interface{}
+		if entry, ok := utils.GetAuditEntry(ctx); ok {
+			if entry.EntityID != uuid.Nil {
+				entityID = entry.EntityID
+			}
+
+			if entry.Before != nil {
+				before = entry.Before
+			}
+
+			after = entry.After
+		}
+
+		if after == nil && load != nil && entityID != uuid.Nil {
+			after, _ = load(entityID)
+		}
+
+		m.recordAudit(ctx, userId, entityType, action, entityID, before, after)
+	}
+}
+
+// AuditAccount records an AuditEvent for the account endpoints that run
+// without a signed in user, such as registering or resetting a password. The
+// handler names the affected user with an utils.AuditEntry, and that user is
+// recorded as the actor.
+func (m *Middleware) AuditAccount(action string, handler gin.HandlerFunc) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		handler(ctx)
+
+		if ctx.Writer.Status() >= 300 {
+			return
+		}
+
+		entry, ok := utils.GetAuditEntry(ctx)
+		if !ok || entry.EntityID == uuid.Nil {
+			log.Printf("audit of %v user failed: handler did not name the user", action)
+			return
+		}
+
+		m.recordAudit(ctx, entry.EntityID, "user", action, entry.EntityID, entry.Before, entry.After)
+	}
+}
+
+func (m *Middleware) recordAudit(ctx *gin.Context, actorID uuid.UUID, entityType string, action string, entityID uuid.UUID, before interface{}, after interface{}) {
+	changes, err := models.AuditChanges(before, after)
+	if err != nil {
+		log.Printf("audit of %v %v failed: %v", action, entityType, err)
+	}
+
+	event := models.AuditEvent{
+		ActorID:    actorID,
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Changes:    changes,
+		IP:         ctx.ClientIP(),
+		UserAgent:  ctx.Request.UserAgent(),
+	}
+
+	if err := (models.AuditService{DB: m.DB}).Record(event); err != nil {
+		log.Printf("audit of %v %v failed: %v", action, entityType, err)
+	}
+}
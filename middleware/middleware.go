@@ -5,6 +5,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/loyalsfc/investrite/models"
 	"github.com/loyalsfc/investrite/response"
 	"github.com/loyalsfc/investrite/utils"
 	"gorm.io/gorm"
@@ -16,7 +17,9 @@ type Middleware struct {
 
 type handlerFunc func(*gin.Context, uuid.UUID)
 
-func (m *Middleware) MiddlewareAuth(handler handlerFunc) gin.HandlerFunc {
+// MiddlewareAuth authenticates the request and, when permissions are given,
+// lets it through only if the user's role grants all of them.
+func (m *Middleware) MiddlewareAuth(handler handlerFunc, permissions ...utils.Permission) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		token, err := utils.GetAccessToken(&ctx.Request.Header)
 
@@ -25,20 +28,41 @@ func (m *Middleware) MiddlewareAuth(handler handlerFunc) gin.HandlerFunc {
 			return
 		}
 
-		tokens, err := utils.ParseToken(token)
+		claims, err := utils.ParseToken(token)
 		if err != nil {
 			fmt.Println(err)
 			response.Error(ctx, 301, fmt.Sprintf("error %v", err))
 			return
 		}
 
-		userId, err := uuid.Parse(fmt.Sprintf("%v", tokens))
+		revoked, err := models.TokenService{DB: m.DB}.IsRevoked(claims.ID)
 
 		if err != nil {
-			response.Error(ctx, 301, fmt.Sprintf("error %v", err))
+			response.Error(ctx, 500, fmt.Sprintf("error %v", err))
+			return
+		}
+
+		if revoked {
+			response.Error(ctx, 301, "error token has been revoked")
+			return
+		}
+
+		granted, err := models.RoleService{DB: m.DB}.GetPermissions(claims.UserID)
+
+		if err != nil {
+			response.Error(ctx, 403, fmt.Sprintf("role error %v", err))
 			return
 		}
 
-		handler(ctx, userId)
+		utils.SetPermissions(ctx, granted)
+
+		for _, permission := range permissions {
+			if !utils.HasPermission(ctx, permission) {
+				response.PermissionError(ctx)
+				return
+			}
+		}
+
+		handler(ctx, claims.UserID)
 	}
 }
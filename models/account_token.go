@@ -0,0 +1,150 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/loyalsfc/investrite/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	PurposeVerifyEmail   = "verify_email"
+	PurposeResetPassword = "reset_password"
+)
+
+var accountTokenTTL = map[string]time.Duration{
+	PurposeVerifyEmail:   48 * time.Hour,
+	PurposeResetPassword: time.Hour,
+}
+
+// AccountToken makes the signed verification and reset tokens single use. The
+// token itself is never stored, only its jti.
+type AccountToken struct {
+	JTI       string     `json:"jti" gorm:"column:jti;unique;not null"`
+	UserID    uuid.UUID  `json:"user_id" gorm:"column:user_id;index;not null"`
+	Purpose   string     `json:"purpose" gorm:"column:purpose;not null"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"column:expires_at;not null"`
+	UsedAt    *time.Time `json:"used_at" gorm:"column:used_at"`
+	gorm.Model
+}
+
+var errInvalidAccountToken = errors.New("invalid or expired token")
+
+// CreateAccountToken issues a token for purpose and retires any earlier one
+// the user has not used yet, so only the latest emailed link works.
+func (u UserService) CreateAccountToken(userID uuid.UUID, purpose string) (string, error) {
+	token, claims, err := utils.GeneratePurposeToken(userID, purpose, accountTokenTTL[purpose])
+	if err != nil {
+		return "", err
+	}
+
+	err = u.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&AccountToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+
+		record := AccountToken{
+			JTI:       claims.ID,
+			UserID:    userID,
+			Purpose:   purpose,
+			ExpiresAt: claims.ExpiresAt,
+		}
+
+		return tx.Create(&record).Error
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// consumeAccountToken checks the signature and expiry, then marks the token
+// used. It must be called inside a transaction.
+func consumeAccountToken(tx *gorm.DB, token string, purpose string) (*AccountToken, error) {
+	claims, err := utils.ParsePurposeToken(token, purpose)
+	if err != nil {
+		return nil, errInvalidAccountToken
+	}
+
+	var record AccountToken
+
+	result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("jti = ? AND purpose = ?", claims.ID, purpose).First(&record)
+	if result.Error != nil || record.UsedAt != nil || record.UserID != claims.UserID {
+		return nil, errInvalidAccountToken
+	}
+
+	now := time.Now()
+	if result := tx.Model(&AccountToken{}).Where("jti = ?", record.JTI).Update("used_at", now); result.Error != nil {
+		return nil, result.Error
+	}
+	record.UsedAt = &now
+
+	return &record, nil
+}
+
+// VerifyEmail marks the address of the token's user as verified and returns
+// that user's id.
+func (u UserService) VerifyEmail(token string) (uuid.UUID, error) {
+	var userID uuid.UUID
+
+	err := u.DB.Transaction(func(tx *gorm.DB) error {
+		record, err := consumeAccountToken(tx, token, PurposeVerifyEmail)
+		if err != nil {
+			return err
+		}
+		userID = record.UserID
+
+		return tx.Model(&User{}).
+			Where("user_id = ? AND email_verified_at IS NULL", record.UserID).
+			Update("email_verified_at", record.UsedAt).Error
+	})
+
+	return userID, err
+}
+
+// ResetPassword sets a new password and ends every session of the user, since
+// a reset usually means the old password can no longer be trusted. It returns
+// the id of the user whose password changed.
+func (u UserService) ResetPassword(token string, password string) (uuid.UUID, error) {
+	if len(password) < 8 {
+		return uuid.Nil, errors.New("password must be at least 8 characters")
+	}
+
+	hashed, err := utils.HashPassword(password)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	var userID uuid.UUID
+
+	err = u.DB.Transaction(func(tx *gorm.DB) error {
+		record, err := consumeAccountToken(tx, token, PurposeResetPassword)
+		if err != nil {
+			return err
+		}
+		userID = record.UserID
+
+		//Receiving the reset mail proves the address as well
+		result := tx.Model(&User{}).Where("user_id = ?", record.UserID).Updates(map[string]interface{}{
+			"password":          hashed,
+			"email_verified_at": gorm.Expr("COALESCE(email_verified_at, ?)", record.UsedAt),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		return tx.Model(&RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", record.UserID).
+			Update("revoked_at", time.Now()).Error
+	})
+
+	return userID, err
+}
@@ -0,0 +1,133 @@
+package models
+
+import (
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type StockAlert struct {
+	ID              uuid.UUID  `json:"id" gorm:"column:id;unique;not null"`
+	ProductID       uuid.UUID  `json:"product_id" gorm:"column:product_id;index;not null"`
+	ProductName     string     `json:"product_name" gorm:"column:product_name;not null"`
+	Quantity        int        `json:"quantity" gorm:"column:quantity;not null"`
+	ReorderPoint    int        `json:"reorder_point" gorm:"column:reorder_point;not null"`
+	ReorderQuantity int        `json:"reorder_quantity" gorm:"column:reorder_quantity;not null"`
+	ResolvedAt      *time.Time `json:"resolved_at" gorm:"column:resolved_at"`
+	gorm.Model
+}
+
+type AlertService struct {
+	DB *gorm.DB
+}
+
+var stockChecks = make(chan uuid.UUID, 1024)
+
+func queueStockCheck(ids ...uuid.UUID) {
+	seen := map[uuid.UUID]bool{}
+
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		select {
+		case stockChecks <- id:
+		default:
+			log.Printf("stock check queue full, product %v left for the next sweep", id)
+		}
+	}
+}
+
+// Run processes queued stock checks and sweeps every product on an interval
+// so that checks dropped from a full queue are still picked up.
+func (a AlertService) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case id := <-stockChecks:
+			if err := a.CheckProduct(id); err != nil {
+				log.Printf("stock check for product %v failed: %v", id, err)
+			}
+		case <-ticker.C:
+			if err := a.CheckAllProducts(); err != nil {
+				log.Printf("stock sweep failed: %v", err)
+			}
+		}
+	}
+}
+
+func (a AlertService) CheckAllProducts() error {
+	var ids []uuid.UUID
+
+	if result := a.DB.Model(&Product{}).Where("reorder_point > 0").Pluck("id", &ids); result.Error != nil {
+		return result.Error
+	}
+
+	for _, id := range ids {
+		if err := a.CheckProduct(id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CheckProduct opens an alert when a product is at or below its reorder point
+// and has no open alert, and resolves open alerts once stock has recovered.
+func (a AlertService) CheckProduct(id uuid.UUID) error {
+	var product Product
+
+	if result := a.DB.Where("id = ?", id).First(&product); result.Error != nil {
+		return result.Error
+	}
+
+	var open StockAlert
+	result := a.DB.Where("product_id = ? AND resolved_at IS NULL", id).Limit(1).Find(&open)
+	if result.Error != nil {
+		return result.Error
+	}
+	hasOpenAlert := result.RowsAffected > 0
+
+	isLow := product.ReorderPoint > 0 && product.Quantity <= product.ReorderPoint
+
+	if isLow && !hasOpenAlert {
+		alert := StockAlert{
+			ID:              uuid.New(),
+			ProductID:       product.ID,
+			ProductName:     product.Name,
+			Quantity:        product.Quantity,
+			ReorderPoint:    product.ReorderPoint,
+			ReorderQuantity: product.ReorderQuantity,
+		}
+
+		return a.DB.Create(&alert).Error
+	}
+
+	if !isLow && hasOpenAlert {
+		now := time.Now()
+		return a.DB.Model(&StockAlert{}).Where("product_id = ? AND resolved_at IS NULL", id).Update("resolved_at", now).Error
+	}
+
+	return nil
+}
+
+func (a AlertService) GetAlerts(includeResolved bool) ([]StockAlert, error) {
+	var alerts []StockAlert
+
+	query := a.DB.Order("created_at desc")
+	if !includeResolved {
+		query = query.Where("resolved_at IS NULL")
+	}
+
+	if result := query.Find(&alerts); result.Error != nil {
+		return nil, result.Error
+	}
+
+	return alerts, nil
+}
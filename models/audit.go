@@ -0,0 +1,159 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"github.com/google/uuid"
+	"github.com/loyalsfc/investrite/data"
+	"gorm.io/gorm"
+)
+
+type AuditEvent struct {
+	ID         uuid.UUID       `json:"id" gorm:"column:id;unique;not null"`
+	ActorID    uuid.UUID       `json:"actor_id" gorm:"column:actor_id;index;not null"`
+	Action     string          `json:"action" gorm:"column:action;not null"`
+	EntityType string          `json:"entity_type" gorm:"column:entity_type;index:idx_audit_entity;not null"`
+	EntityID   uuid.UUID       `json:"entity_id" gorm:"column:entity_id;index:idx_audit_entity"`
+	Changes    json.RawMessage `json:"changes" gorm:"column:changes;type:jsonb"`
+	IP         string          `json:"ip" gorm:"column:ip"`
+	UserAgent  string          `json:"user_agent" gorm:"column:user_agent"`
+	gorm.Model
+}
+
+type FieldChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+type AuditService struct {
+	DB *gorm.DB
+}
+
+// auditIgnoredFields change on every write or must never be copied into the
+// log.
+var auditIgnoredFields = map[string]bool{
+	"UpdatedAt": true,
+	"password":  true,
+}
+
+// AuditChanges compares the JSON form of two snapshots and keeps only the
+// fields that differ. A nil before marks a create and a nil after a delete.
+func AuditChanges(before interface{}, after interface{}) (json.RawMessage, error) {
+	beforeFields, err := auditFields(before)
+	if err != nil {
+		return nil, err
+	}
+
+	afterFields, err := auditFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := map[string]FieldChange{}
+
+	for key, value := range beforeFields {
+		if !reflect.DeepEqual(value, afterFields[key]) {
+			changes[key] = FieldChange{Before: value, After: afterFields[key]}
+		}
+	}
+
+	for key, value := range afterFields {
+		if _, ok := beforeFields[key]; !ok {
+			changes[key] = FieldChange{After: value}
+		}
+	}
+
+	for key := range auditIgnoredFields {
+		delete(changes, key)
+	}
+
+	if len(changes) == 0 {
+		return nil, nil
+	}
+
+	return json.Marshal(changes)
+}
+
+// auditFields flattens a snapshot into its top level JSON fields. Snapshots
+// that are not objects, such as the result list of a bulk adjustment, are
+// kept whole under "value".
+func auditFields(snapshot interface{}) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+
+	if snapshot == nil {
+		return fields, nil
+	}
+
+	raw, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		var value interface{}
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return nil, err
+		}
+
+		return map[string]interface{}{"value": value}, nil
+	}
+
+	return fields, nil
+}
+
+func (a AuditService) Record(event AuditEvent) error {
+	event.ID = uuid.New()
+
+	if result := a.DB.Create(&event); result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
+
+var auditSort = listSort{
+	Columns: map[string]string{
+		"created_at": "created_at",
+		"action":     "action",
+	},
+	DefaultKey:   "created_at",
+	DefaultOrder: "desc",
+}
+
+func (a AuditService) GetAuditEvents(filter data.AuditFilter) ([]AuditEvent, *ListMeta, error) {
+	var events []AuditEvent
+
+	query := a.DB.Model(&AuditEvent{})
+
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+
+	if !filter.To.IsZero() {
+		query = query.Where("created_at < ?", filter.To.AddDate(0, 0, 1))
+	}
+
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+
+	if filter.EntityType != "" {
+		query = query.Where("entity_type = ?", filter.EntityType)
+	}
+
+	if filter.EntityID != uuid.Nil {
+		query = query.Where("entity_id = ?", filter.EntityID)
+	}
+
+	if filter.ActorID != uuid.Nil {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+
+	meta, err := paginate(query, filter.ListParams, auditSort, &events)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return events, meta, nil
+}
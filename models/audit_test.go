@@ -0,0 +1,81 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type auditSnapshot struct {
+	Name      string    `json:"name"`
+	Quantity  int       `json:"quantity"`
+	Password  string    `json:"password,omitempty"`
+	UpdatedAt time.Time `json:"UpdatedAt"`
+}
+
+func TestAuditChanges(t *testing.T) {
+	earlier := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	later := earlier.Add(time.Hour)
+
+	tests := []struct {
+		name   string
+		before interface{}
+		after  interface{}
+		want   map[string]FieldChange
+	}{
+		{
+			name:   "update keeps only changed fields",
+			before: auditSnapshot{Name: "rice", Quantity: 5, UpdatedAt: earlier},
+			after:  auditSnapshot{Name: "rice", Quantity: 7, UpdatedAt: later},
+			want:   map[string]FieldChange{"quantity": {Before: 5.0, After: 7.0}},
+		},
+		{
+			name:   "passwords are never recorded",
+			before: auditSnapshot{Name: "user", Password: "old-hash"},
+			after:  auditSnapshot{Name: "user", Password: "new-hash"},
+			want:   nil,
+		},
+		{
+			name:  "create has no before",
+			after: auditSnapshot{Name: "rice", Quantity: 5, Password: "hash", UpdatedAt: later},
+			want:  map[string]FieldChange{"name": {After: "rice"}, "quantity": {After: 5.0}},
+		},
+		{
+			name:   "delete has no after",
+			before: auditSnapshot{Name: "rice", Quantity: 5},
+			want:   map[string]FieldChange{"name": {Before: "rice"}, "quantity": {Before: 5.0}},
+		},
+		{
+			name:   "snapshots that are not objects are compared whole",
+			before: []int{1},
+			after:  []int{1, 2},
+			want:   map[string]FieldChange{"value": {Before: []interface{}{1.0}, After: []interface{}{1.0, 2.0}}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			raw, err := AuditChanges(test.before, test.after)
+			if err != nil {
+				t.Fatalf("AuditChanges: %v", err)
+			}
+
+			if test.want == nil {
+				if raw != nil {
+					t.Fatalf("got %s, want no changes", raw)
+				}
+				return
+			}
+
+			var got map[string]FieldChange
+			if err := json.Unmarshal(raw, &got); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 
 	"github.com/google/uuid"
+	"github.com/loyalsfc/investrite/data"
 	"github.com/loyalsfc/investrite/utils"
 	"gorm.io/gorm"
 )
@@ -102,14 +103,22 @@ func (c CategoryModel) EditCategory(name string, catID uuid.UUID) error {
 	return nil
 }
 
-func (c CategoryModel) CategoryList() ([]Category, error) {
-	var categories []Category
+var categorySort = listSort{
+	Columns: map[string]string{
+		"name":       "name",
+		"created_at": "created_at",
+	},
+	DefaultKey:   "name",
+	DefaultOrder: "asc",
+}
 
-	result := c.DB.Find(&categories)
+func (c CategoryModel) CategoryList(params data.ListParams) ([]Category, *ListMeta, error) {
+	var categories []Category
 
-	if result.Error != nil {
-		return nil, result.Error
+	meta, err := paginate(c.DB.Model(&Category{}), params, categorySort, &categories)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return categories, nil
+	return categories, meta, nil
 }
@@ -0,0 +1,142 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CostLayer is a batch of stock that came in at one unit cost. Sales and
+// other outgoing movements consume layers oldest first, which gives FIFO
+// cost of goods sold and leaves the remaining layers as the stock's value.
+type CostLayer struct {
+	ID         uuid.UUID `json:"id" gorm:"column:id;unique;not null"`
+	ProductID  uuid.UUID `json:"product_id" gorm:"column:product_id;index;not null"`
+	VariantID  uuid.UUID `json:"variant_id" gorm:"column:variant_id"`
+	MovementID uuid.UUID `json:"movement_id" gorm:"column:movement_id"`
+	UnitCost   int       `json:"unit_cost" gorm:"column:unit_cost;not null"`
+	Quantity   int       `json:"quantity" gorm:"column:quantity;not null"`
+	Remaining  int       `json:"remaining" gorm:"column:remaining;not null"`
+	gorm.Model
+}
+
+// recordCost books the cost side of a stock change and returns its total
+// cost. Incoming stock opens a layer at the change's unit cost, or the
+// product's cost price when none is given. A total cost that does not divide
+// evenly is split over two layers a unit apart, so the layers add up to it
+// exactly. Transfers only move stock between locations, so they leave the
+// layers alone.
+func recordCost(tx *gorm.DB, product *Product, change stockChange, movementID uuid.UUID) (int, error) {
+	if change.Reason == MovementTransferIn || change.Reason == MovementTransferOut {
+		return 0, nil
+	}
+
+	if change.Delta > 0 {
+		total := product.CostPrice * change.Delta
+		if change.UnitCost != nil {
+			total = *change.UnitCost * change.Delta
+		}
+		if change.TotalCost != nil {
+			total = *change.TotalCost
+		}
+
+		unitCost := total / change.Delta
+		extra := total - unitCost*change.Delta
+
+		for _, batch := range [][2]int{{unitCost + 1, extra}, {unitCost, change.Delta - extra}} {
+			if batch[1] == 0 {
+				continue
+			}
+
+			layer := CostLayer{
+				ID:         uuid.New(),
+				ProductID:  product.ID,
+				VariantID:  change.VariantID,
+				MovementID: movementID,
+				UnitCost:   batch[0],
+				Quantity:   batch[1],
+				Remaining:  batch[1],
+			}
+
+			if result := tx.Create(&layer); result.Error != nil {
+				return 0, result.Error
+			}
+		}
+
+		return total, nil
+	}
+
+	var layers []CostLayer
+	result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("product_id = ? AND variant_id = ? AND remaining > 0", product.ID, change.VariantID).
+		Order("created_at asc").
+		Find(&layers)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	needed := -change.Delta
+	cost := 0
+
+	for _, layer := range layers {
+		if needed == 0 {
+			break
+		}
+
+		taken := layer.Remaining
+		if taken > needed {
+			taken = needed
+		}
+
+		if result := tx.Model(&CostLayer{}).Where("id = ?", layer.ID).Update("remaining", layer.Remaining-taken); result.Error != nil {
+			return 0, result.Error
+		}
+
+		cost = cost + taken*layer.UnitCost
+		needed = needed - taken
+	}
+
+	//Stock that predates cost tracking has no layer left to draw from
+	cost = cost + needed*product.CostPrice
+
+	return cost, nil
+}
+
+// MigrateCostLayers opens a layer at the current cost price for stock that
+// was on hand before cost tracking existed.
+func MigrateCostLayers(db *gorm.DB) error {
+	var balances []struct {
+		ProductID uuid.UUID
+		VariantID uuid.UUID
+		Quantity  int
+		CostPrice int
+	}
+
+	result := db.Model(&ProductStock{}).
+		Select("product_stocks.product_id, product_stocks.variant_id, SUM(product_stocks.quantity) AS quantity, products.cost_price").
+		Joins("JOIN products ON products.id = product_stocks.product_id").
+		Where("NOT EXISTS (SELECT 1 FROM cost_layers WHERE cost_layers.product_id = product_stocks.product_id AND cost_layers.variant_id = product_stocks.variant_id)").
+		Group("product_stocks.product_id, product_stocks.variant_id, products.cost_price").
+		Having("SUM(product_stocks.quantity) > 0").
+		Scan(&balances)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	for _, balance := range balances {
+		layer := CostLayer{
+			ID:        uuid.New(),
+			ProductID: balance.ProductID,
+			VariantID: balance.VariantID,
+			UnitCost:  balance.CostPrice,
+			Quantity:  balance.Quantity,
+			Remaining: balance.Quantity,
+		}
+
+		if result := db.Create(&layer); result.Error != nil {
+			return result.Error
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"database/sql/driver"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestRecordCostSplitsUnevenTotals(t *testing.T) {
+	db, fake := newFakeDB(t, func(string, []driver.Value) *fakeRows { return nil })
+
+	product := &Product{ID: uuid.New(), CostPrice: 100}
+	total := 10
+
+	cost, err := recordCost(db, product, stockChange{
+		ProductID: product.ID,
+		Delta:     3,
+		Reason:    MovementReturn,
+		TotalCost: &total,
+	}, uuid.New())
+	if err != nil {
+		t.Fatalf("recordCost: %v", err)
+	}
+
+	if cost != 10 {
+		t.Errorf("got cost %d, want 10", cost)
+	}
+
+	layers := fake.Matching(`INSERT INTO "cost_layers"`)
+	if len(layers) != 2 {
+		t.Fatalf("got %d cost layers, want 2", len(layers))
+	}
+
+	//One unit at 4 and two at 3 add back up to 10
+	if !hasArg(layers[0].Args, int64(4)) || !hasArg(layers[0].Args, int64(1)) {
+		t.Errorf("first layer args %v, want one unit at 4", layers[0].Args)
+	}
+	if !hasArg(layers[1].Args, int64(3)) || !hasArg(layers[1].Args, int64(2)) {
+		t.Errorf("second layer args %v, want two units at 3", layers[1].Args)
+	}
+}
+
+func TestRecordCostEvenTotalUsesOneLayer(t *testing.T) {
+	db, fake := newFakeDB(t, func(string, []driver.Value) *fakeRows { return nil })
+
+	product := &Product{ID: uuid.New(), CostPrice: 100}
+	unitCost := 25
+
+	cost, err := recordCost(db, product, stockChange{
+		ProductID: product.ID,
+		Delta:     4,
+		Reason:    MovementRestock,
+		UnitCost:  &unitCost,
+	}, uuid.New())
+	if err != nil {
+		t.Fatalf("recordCost: %v", err)
+	}
+
+	if cost != 100 {
+		t.Errorf("got cost %d, want 100", cost)
+	}
+
+	if got := len(fake.Matching(`INSERT INTO "cost_layers"`)); got != 1 {
+		t.Errorf("got %d cost layers, want 1", got)
+	}
+}
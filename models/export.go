@@ -0,0 +1,234 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/loyalsfc/investrite/data"
+	"gorm.io/gorm"
+)
+
+// Export walks the rows of a query one at a time. It satisfies
+// spreadsheet.RowSource so handlers can stream it straight to the client.
+type Export struct {
+	db      *gorm.DB
+	rows    *sql.Rows
+	columns []string
+	scan    func(db *gorm.DB, rows *sql.Rows) ([]interface{}, error)
+}
+
+func (e *Export) Columns() []string {
+	return e.columns
+}
+
+func (e *Export) Next() bool {
+	return e.rows.Next()
+}
+
+func (e *Export) Row() ([]interface{}, error) {
+	return e.scan(e.db, e.rows)
+}
+
+func (e *Export) Err() error {
+	return e.rows.Err()
+}
+
+func (e *Export) Close() error {
+	return e.rows.Close()
+}
+
+func (p ProductService) ExportProducts(filter data.ProductFilter) (*Export, error) {
+	_, _, clause, err := resolveSort(filter.ListParams, productSort)
+	if err != nil {
+		return nil, err
+	}
+
+	var categories []Category
+	if result := p.DB.Unscoped().Find(&categories); result.Error != nil {
+		return nil, result.Error
+	}
+
+	categoryNames := map[uuid.UUID]string{}
+	for _, category := range categories {
+		categoryNames[category.ID] = category.Name
+	}
+
+	rows, err := productQuery(p.DB, filter).Order(clause).Rows()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Export{
+		db:   p.DB,
+		rows: rows,
+		columns: []string{
+			"id", "name", "sku", "barcode", "category", "description", "price", "cost_price",
+			"quantity", "reorder_point", "reorder_quantity", "created_at", "updated_at",
+		},
+		scan: func(db *gorm.DB, rows *sql.Rows) ([]interface{}, error) {
+			var product Product
+			if err := db.ScanRows(rows, &product); err != nil {
+				return nil, err
+			}
+
+			return []interface{}{
+				product.ID.String(), product.Name, product.SKU, product.Barcode, categoryNames[product.CategoryId],
+				product.Description, product.Price, product.CostPrice, product.Quantity, product.ReorderPoint,
+				product.ReorderQuantity, product.CreatedAt, product.UpdatedAt,
+			}, nil
+		},
+	}, nil
+}
+
+type orderExportRow struct {
+	OrderID          uuid.UUID
+	CreatedAt        time.Time
+	Status           Status
+	LocationID       uuid.UUID
+	TotalPrice       int
+	Cash             int
+	Transfer         int
+	Pos              int
+	ItemID           uuid.UUID
+	ProductID        uuid.UUID
+	ProductName      string
+	VariantName      string
+	Quantity         int
+	UnitPrice        int
+	LineTotal        int
+	RefundedQuantity int
+}
+
+// ExportOrders writes one row per order line, repeating the order's status
+// and payment split on each of its lines.
+func (o OrderService) ExportOrders(filter data.OrderFilter) (*Export, error) {
+	_, _, clause, err := resolveSort(filter.ListParams, orderSort)
+	if err != nil {
+		return nil, err
+	}
+
+	query, err := orderQuery(o.DB, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := query.
+		Select(`orders.order_id, orders.created_at, orders.status, orders.location_id, orders.total_price,
+			orders.cash, orders.transfer, orders.pos, order_items.id AS item_id, order_items.product_id,
+			order_items.product_name, order_items.variant_name, order_items.quantity, order_items.unit_price,
+			order_items.line_total, order_items.refunded_quantity`).
+		Joins("JOIN order_items ON order_items.order_id = orders.order_id AND order_items.deleted_at IS NULL").
+		Order(clause).
+		Order("orders.order_id, order_items.created_at").
+		Rows()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Export{
+		db:   o.DB,
+		rows: rows,
+		columns: []string{
+			"order_id", "created_at", "status", "location_id", "order_total", "cash", "transfer", "pos",
+			"item_id", "product_id", "product_name", "variant", "quantity", "unit_price", "line_total", "refunded_quantity",
+		},
+		scan: func(db *gorm.DB, rows *sql.Rows) ([]interface{}, error) {
+			var row orderExportRow
+			if err := db.ScanRows(rows, &row); err != nil {
+				return nil, err
+			}
+
+			return []interface{}{
+				row.OrderID.String(), row.CreatedAt, string(row.Status), row.LocationID.String(), row.TotalPrice,
+				row.Cash, row.Transfer, row.Pos, row.ItemID.String(), row.ProductID.String(), row.ProductName,
+				row.VariantName, row.Quantity, row.UnitPrice, row.LineTotal, row.RefundedQuantity,
+			}, nil
+		},
+	}, nil
+}
+
+func (u UserService) ExportUsers(filter data.UserFilter) (*Export, error) {
+	_, _, clause, err := resolveSort(filter.ListParams, userSort)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := userQuery(u.DB, filter).Order(clause).Rows()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Export{
+		db:      u.DB,
+		rows:    rows,
+		columns: []string{"user_id", "first_name", "last_name", "email", "role", "created_at"},
+		scan: func(db *gorm.DB, rows *sql.Rows) ([]interface{}, error) {
+			var user User
+			if err := db.ScanRows(rows, &user); err != nil {
+				return nil, err
+			}
+
+			return []interface{}{
+				user.UserID.String(), user.FirstName, user.LastName, user.Email, user.Role, user.CreatedAt,
+			}, nil
+		},
+	}, nil
+}
+
+type movementExportRow struct {
+	StockMovement
+	ProductName string
+}
+
+func (p ProductService) ExportMovements(filter data.MovementFilter) (*Export, error) {
+	query := p.DB.Model(&StockMovement{}).
+		Select("stock_movements.*, products.name AS product_name").
+		Joins("JOIN products ON products.id = stock_movements.product_id")
+
+	if !filter.From.IsZero() {
+		query = query.Where("stock_movements.created_at >= ?", filter.From)
+	}
+
+	if !filter.To.IsZero() {
+		query = query.Where("stock_movements.created_at < ?", filter.To.AddDate(0, 0, 1))
+	}
+
+	if filter.Reason != "" {
+		query = query.Where("stock_movements.reason = ?", filter.Reason)
+	}
+
+	if filter.ProductID != uuid.Nil {
+		query = query.Where("stock_movements.product_id = ?", filter.ProductID)
+	}
+
+	if filter.LocationID != uuid.Nil {
+		query = query.Where("stock_movements.location_id = ?", filter.LocationID)
+	}
+
+	rows, err := query.Order("stock_movements.created_at asc").Rows()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Export{
+		db:   p.DB,
+		rows: rows,
+		columns: []string{
+			"id", "created_at", "product_id", "product_name", "variant_id", "location_id", "reason",
+			"delta", "quantity_after", "cost", "reference_id", "user_id", "note",
+		},
+		scan: func(db *gorm.DB, rows *sql.Rows) ([]interface{}, error) {
+			var row movementExportRow
+			if err := db.ScanRows(rows, &row); err != nil {
+				return nil, err
+			}
+
+			return []interface{}{
+				row.ID.String(), row.CreatedAt, row.ProductID.String(), row.ProductName, row.VariantID.String(),
+				row.LocationID.String(), string(row.Reason), row.Delta, row.QuantityAfter, row.Cost,
+				row.ReferenceID.String(), row.UserID.String(), row.Note,
+			}, nil
+		},
+	}, nil
+}
@@ -0,0 +1,135 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// fakeStatement is one query or exec the fake driver received.
+type fakeStatement struct {
+	SQL  string
+	Args []driver.Value
+}
+
+// fakeRows answers a query with a set of columns and rows.
+type fakeRows struct {
+	Columns []string
+	Values  [][]driver.Value
+}
+
+// fakeResponder picks the rows for a query; returning nil answers with no rows.
+type fakeResponder func(query string, args []driver.Value) *fakeRows
+
+// fakeDB records every statement gorm sends so tests can check the SQL a
+// service runs without a database.
+type fakeDB struct {
+	mu         sync.Mutex
+	statements []fakeStatement
+	respond    fakeResponder
+}
+
+func newFakeDB(t *testing.T, respond fakeResponder) (*gorm.DB, *fakeDB) {
+	t.Helper()
+
+	fake := &fakeDB{respond: respond}
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sql.OpenDB(fake)}), &gorm.Config{
+		Logger: logger.Discard,
+	})
+	if err != nil {
+		t.Fatalf("open fake db: %v", err)
+	}
+
+	return db, fake
+}
+
+// Matching returns the statements whose SQL contains fragment.
+func (f *fakeDB) Matching(fragment string) []fakeStatement {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var matched []fakeStatement
+	for _, statement := range f.statements {
+		if strings.Contains(statement.SQL, fragment) {
+			matched = append(matched, statement)
+		}
+	}
+
+	return matched
+}
+
+func (f *fakeDB) record(query string, args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+
+	f.mu.Lock()
+	f.statements = append(f.statements, fakeStatement{SQL: query, Args: values})
+	f.mu.Unlock()
+
+	return values
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return &fakeConn{db: f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) { return nil, driver.ErrSkip }
+func (c *fakeConn) Close() error                        { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)           { return fakeTx{}, nil }
+
+func (c *fakeConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	return fakeTx{}, nil
+}
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.db.record(query, args)
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	values := c.db.record(query, args)
+
+	rows := c.db.respond(query, values)
+	if rows == nil {
+		rows = &fakeRows{}
+	}
+
+	return &fakeCursor{rows: rows}, nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeCursor struct {
+	rows *fakeRows
+	next int
+}
+
+func (r *fakeCursor) Columns() []string { return r.rows.Columns }
+func (r *fakeCursor) Close() error      { return nil }
+
+func (r *fakeCursor) Next(dest []driver.Value) error {
+	if r.next >= len(r.rows.Values) {
+		return io.EOF
+	}
+
+	copy(dest, r.rows.Values[r.next])
+	r.next++
+	return nil
+}
@@ -0,0 +1,269 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/loyalsfc/investrite/data"
+	"github.com/loyalsfc/investrite/utils"
+	"gorm.io/gorm"
+)
+
+// MaxImportRows is the most products one import can create; the sheet may
+// have one more row for its header.
+const MaxImportRows = 5000
+
+type ImportRowError struct {
+	Row    int      `json:"row"`
+	Errors []string `json:"errors"`
+}
+
+type ImportResult struct {
+	DryRun            bool             `json:"dry_run"`
+	Rows              int              `json:"rows"`
+	Valid             int              `json:"valid"`
+	Imported          int              `json:"imported"`
+	CreatedCategories []string         `json:"created_categories"`
+	Errors            []ImportRowError `json:"errors"`
+}
+
+// importColumns maps accepted header names to the product field they fill.
+var importColumns = map[string]string{
+	"name":             "name",
+	"description":      "description",
+	"quantity":         "quantity",
+	"price":            "price",
+	"cost_price":       "cost_price",
+	"cost":             "cost_price",
+	"image":            "image",
+	"category":         "category",
+	"reorder_point":    "reorder_point",
+	"reorder_quantity": "reorder_quantity",
+	"sku":              "sku",
+	"barcode":          "barcode",
+}
+
+type importRow struct {
+	Line     int
+	Params   data.AddProductParams
+	Category string
+}
+
+// ImportProducts validates every row of a product sheet before touching the
+// database. Rows that fail are reported with their line number; unless this is
+// a dry run, the remaining rows are created together in one transaction.
+func (p ProductService) ImportProducts(rows [][]string, param data.ImportProductsParams, userId uuid.UUID) (*ImportResult, error) {
+	if len(rows) < 2 {
+		return nil, errors.New("sheet needs a header row and at least one product")
+	}
+
+	if len(rows)-1 > MaxImportRows {
+		return nil, fmt.Errorf("cannot import more than %v rows at once", MaxImportRows)
+	}
+
+	columns := map[string]int{}
+	for i, header := range rows[0] {
+		key := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(header), " ", "_"))
+		if field, ok := importColumns[key]; ok {
+			columns[field] = i
+		}
+	}
+
+	for _, required := range []string{"name", "price", "category"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("sheet is missing the %v column", required)
+		}
+	}
+
+	var categories []Category
+	if result := p.DB.Find(&categories); result.Error != nil {
+		return nil, result.Error
+	}
+
+	categoryIDs := map[string]uuid.UUID{}
+	for _, category := range categories {
+		categoryIDs[strings.ToLower(category.Name)] = category.ID
+		categoryIDs[strings.ToLower(category.Slug)] = category.ID
+	}
+
+	summary := &ImportResult{DryRun: param.DryRun, CreatedCategories: []string{}, Errors: []ImportRowError{}}
+	newCategories := map[string]string{}
+	seen := map[string]int{}
+
+	var valid []importRow
+
+	for i, cells := range rows[1:] {
+		line := i + 2
+
+		cell := func(field string) string {
+			index, ok := columns[field]
+			if !ok || index >= len(cells) {
+				return ""
+			}
+			return strings.TrimSpace(cells[index])
+		}
+
+		if isBlankRow(cells) {
+			continue
+		}
+		summary.Rows = summary.Rows + 1
+
+		var problems []string
+		number := func(field string) int {
+			value := cell(field)
+			if value == "" {
+				return 0
+			}
+
+			n, err := parseWholeNumber(value)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%v must be a whole number", field))
+			}
+			return n
+		}
+
+		row := importRow{
+			Line:     line,
+			Category: cell("category"),
+			Params: data.AddProductParams{
+				Name:            cell("name"),
+				Description:     cell("description"),
+				Quantity:        number("quantity"),
+				Price:           number("price"),
+				CostPrice:       number("cost_price"),
+				Image:           cell("image"),
+				ReorderPoint:    number("reorder_point"),
+				ReorderQuantity: number("reorder_quantity"),
+				SKU:             cell("sku"),
+				Barcode:         cell("barcode"),
+				LocationID:      param.LocationID,
+			},
+		}
+
+		if cell("price") == "" {
+			problems = append(problems, "price is required")
+		} else if row.Params.Price < 0 {
+			problems = append(problems, "price cannot be negative")
+		}
+
+		if err := validateProduct(&row.Params); err != nil {
+			problems = append(problems, err.Error())
+		}
+
+		categoryKey := strings.ToLower(row.Category)
+		if id, ok := categoryIDs[categoryKey]; ok {
+			row.Params.CategoryId = id
+		} else if row.Category == "" {
+			problems = append(problems, "category is required")
+		} else if !param.CreateCategories {
+			problems = append(problems, fmt.Sprintf("category %v does not exist", row.Category))
+		} else if len(row.Category) < 3 {
+			problems = append(problems, "category name cannot be less than 3")
+		} else if _, ok := newCategories[categoryKey]; !ok {
+			newCategories[categoryKey] = row.Category
+		}
+
+		//Slug, SKU and barcode must be unique within the file as well as the database
+		keys := []string{"slug:" + utils.GenerateSlugs(row.Params.Name)}
+		for _, code := range []string{row.Params.SKU, row.Params.Barcode} {
+			if code != "" {
+				keys = append(keys, "code:"+code)
+			}
+		}
+
+		for _, key := range keys {
+			if first, ok := seen[key]; ok {
+				problems = append(problems, fmt.Sprintf("%v duplicates row %v", strings.SplitN(key, ":", 2)[1], first))
+			} else {
+				seen[key] = line
+			}
+		}
+
+		var existing int64
+		if result := p.DB.Model(&Product{}).Where("slug = ?", utils.GenerateSlugs(row.Params.Name)).Count(&existing); result.Error != nil {
+			return nil, result.Error
+		}
+		if existing > 0 {
+			problems = append(problems, fmt.Sprintf("a product named %v already exists", row.Params.Name))
+		}
+
+		if err := ensureUniqueCodes(p.DB, uuid.Nil, uuid.Nil, row.Params.SKU, row.Params.Barcode); err != nil {
+			problems = append(problems, err.Error())
+		}
+
+		if len(problems) > 0 {
+			summary.Errors = append(summary.Errors, ImportRowError{Row: line, Errors: problems})
+			continue
+		}
+
+		valid = append(valid, row)
+	}
+
+	summary.Valid = len(valid)
+
+	if param.DryRun || len(valid) == 0 {
+		for _, name := range newCategories {
+			summary.CreatedCategories = append(summary.CreatedCategories, name)
+		}
+		return summary, nil
+	}
+
+	err := stockTransaction(p.DB, func(tx *gorm.DB) error {
+		for key, name := range newCategories {
+			category := Category{ID: uuid.New(), Name: name, Slug: utils.GenerateSlugs(name)}
+
+			if result := tx.Create(&category); result.Error != nil {
+				return fmt.Errorf("category %v: %v", name, result.Error)
+			}
+
+			categoryIDs[key] = category.ID
+			summary.CreatedCategories = append(summary.CreatedCategories, name)
+		}
+
+		for _, row := range valid {
+			if row.Params.CategoryId == uuid.Nil {
+				row.Params.CategoryId = categoryIDs[strings.ToLower(row.Category)]
+			}
+
+			if _, err := insertProduct(tx, &row.Params, userId); err != nil {
+				return fmt.Errorf("row %v: %v", row.Line, err)
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	summary.Imported = len(valid)
+	return summary, nil
+}
+
+func isBlankRow(cells []string) bool {
+	for _, cell := range cells {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// parseWholeNumber accepts "12" as well as "12.0", which spreadsheets often
+// produce for numeric cells.
+func parseWholeNumber(value string) (int, error) {
+	if n, err := strconv.Atoi(value); err == nil {
+		return n, nil
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f != float64(int(f)) {
+		return 0, errors.New("not a whole number")
+	}
+
+	return int(f), nil
+}
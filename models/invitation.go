@@ -0,0 +1,182 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/loyalsfc/investrite/data"
+	"github.com/loyalsfc/investrite/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	PurposeInvite = "invite"
+	invitationTTL = 7 * 24 * time.Hour
+)
+
+// Invitation lets an admin create a staff account with its role decided up
+// front. Only the latest token sent for an invitation is accepted.
+type Invitation struct {
+	ID         uuid.UUID      `json:"id" gorm:"column:id;unique;not null"`
+	Email      string         `json:"email" gorm:"column:email;index;not null"`
+	Role       utils.UserRole `json:"role" gorm:"column:role;not null"`
+	InvitedBy  uuid.UUID      `json:"invited_by" gorm:"column:invited_by;not null"`
+	JTI        string         `json:"-" gorm:"column:jti;not null"`
+	ExpiresAt  time.Time      `json:"expires_at" gorm:"column:expires_at;not null"`
+	AcceptedAt *time.Time     `json:"accepted_at" gorm:"column:accepted_at"`
+	UserID     uuid.UUID      `json:"user_id" gorm:"column:user_id"`
+	gorm.Model
+}
+
+// CreateInvitation replaces any pending invitation for the same email and
+// returns the token to mail to the invitee. The token's subject is the
+// invitation id, as there is no user yet.
+func (u UserService) CreateInvitation(param data.InviteUserParams, invitedBy uuid.UUID) (*Invitation, string, error) {
+	email := strings.TrimSpace(param.Email)
+
+	if !strings.Contains(email, "@") {
+		return nil, "", errors.New("invalid email address")
+	}
+
+	if !(RoleService{DB: u.DB}).RoleExists(string(param.Role)) {
+		return nil, "", errors.New("the provided role is invalid")
+	}
+
+	if u.IsUserExist(email) {
+		return nil, "", errors.New("user with the email already exist")
+	}
+
+	invitation := Invitation{
+		ID:        uuid.New(),
+		Email:     email,
+		Role:      param.Role,
+		InvitedBy: invitedBy,
+	}
+
+	token, claims, err := utils.GeneratePurposeToken(invitation.ID, PurposeInvite, invitationTTL)
+	if err != nil {
+		return nil, "", err
+	}
+
+	invitation.JTI = claims.ID
+	invitation.ExpiresAt = claims.ExpiresAt
+
+	err = u.DB.Transaction(func(tx *gorm.DB) error {
+		if result := tx.Where("email = ? AND accepted_at IS NULL", email).Delete(&Invitation{}); result.Error != nil {
+			return result.Error
+		}
+
+		return tx.Create(&invitation).Error
+	})
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &invitation, token, nil
+}
+
+func (u UserService) GetInvitations(pending bool) ([]Invitation, error) {
+	var invitations []Invitation
+
+	query := u.DB.Order("created_at desc")
+	if pending {
+		query = query.Where("accepted_at IS NULL AND expires_at > ?", time.Now())
+	}
+
+	if result := query.Find(&invitations); result.Error != nil {
+		return nil, result.Error
+	}
+
+	return invitations, nil
+}
+
+func (u UserService) GetInvitation(id uuid.UUID) (*Invitation, error) {
+	var invitation Invitation
+
+	if result := u.DB.Where("id = ?", id).First(&invitation); result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &invitation, nil
+}
+
+func (u UserService) RevokeInvitation(id uuid.UUID) error {
+	result := u.DB.Where("id = ? AND accepted_at IS NULL", id).Delete(&Invitation{})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return errors.New("no pending invitation found")
+	}
+
+	return nil
+}
+
+// AcceptInvitation creates the invitee's account with the invited role. The
+// address counts as verified since the invitee received the link there.
+func (u UserService) AcceptInvitation(param data.AcceptInviteParams) (*User, error) {
+	if len(param.Password) < 8 {
+		return nil, errors.New("password must be at least 8 characters")
+	}
+
+	if param.FirstName == "" || param.LastName == "" {
+		return nil, errors.New("first_name and last_name are required")
+	}
+
+	claims, err := utils.ParsePurposeToken(param.Token, PurposeInvite)
+	if err != nil {
+		return nil, errInvalidAccountToken
+	}
+
+	password, err := utils.HashPassword(param.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	var user User
+
+	err = u.DB.Transaction(func(tx *gorm.DB) error {
+		var invitation Invitation
+
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", claims.UserID).First(&invitation)
+		if result.Error != nil || invitation.JTI != claims.ID || invitation.AcceptedAt != nil {
+			return errInvalidAccountToken
+		}
+
+		if tx.Where("email = ?", invitation.Email).First(&User{}).Error == nil {
+			return errors.New("user with the email already exist")
+		}
+
+		now := time.Now()
+
+		user = User{
+			FirstName:       param.FirstName,
+			LastName:        param.LastName,
+			Email:           invitation.Email,
+			Password:        password,
+			Role:            string(invitation.Role),
+			UserID:          uuid.New(),
+			EmailVerifiedAt: &now,
+		}
+
+		if result := tx.Create(&user); result.Error != nil {
+			return result.Error
+		}
+
+		return tx.Model(&Invitation{}).Where("id = ?", invitation.ID).Updates(map[string]interface{}{
+			"accepted_at": now,
+			"user_id":     user.UserID,
+		}).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
@@ -10,17 +10,30 @@ import (
 )
 
 type Product struct {
-	ID          uuid.UUID `json:"id" gorm:"column:id;primarykey;not null;unique"`
-	Name        string    `json:"name" gorm:"column:name;not null"`
-	Description string    `json:"description" gorm:"column:description"`
-	Quantity    int       `json:"quantity" gorm:"column:quantity;default:0;check=>0;not null"`
-	Price       int       `json:"price" gorm:"column:price;not null"`
-	Image       string    `json:"image" gorm:"column:image;"`
-	CategoryId  uuid.UUID `json:"category_id" gorm:"column:category_id;not null"`
-	Slug        string    `json:"slug" gorm:"column:slug;not null;unique"`
+	ID              uuid.UUID `json:"id" gorm:"column:id;primarykey;not null;unique"`
+	Name            string    `json:"name" gorm:"column:name;not null"`
+	Description     string    `json:"description" gorm:"column:description"`
+	Quantity        int       `json:"quantity" gorm:"column:quantity;default:0;check=>0;not null"`
+	Price           int       `json:"price" gorm:"column:price;not null"`
+	CostPrice       int       `json:"cost_price" gorm:"column:cost_price;default:0;not null"`
+	Image           string    `json:"image" gorm:"column:image;"`
+	CategoryId      uuid.UUID `json:"category_id" gorm:"column:category_id;not null"`
+	Slug            string    `json:"slug" gorm:"column:slug;not null;unique"`
+	SKU             string    `json:"sku" gorm:"column:sku;index:idx_products_sku,unique,where:sku <> '' AND deleted_at IS NULL"`
+	Barcode         string    `json:"barcode" gorm:"column:barcode;index:idx_products_barcode,unique,where:barcode <> '' AND deleted_at IS NULL"`
+	ReorderPoint    int       `json:"reorder_point" gorm:"column:reorder_point;default:0;not null"`
+	ReorderQuantity int       `json:"reorder_quantity" gorm:"column:reorder_quantity;default:0;not null"`
 	gorm.Model
 }
 
+type ProductDetail struct {
+	Product
+	Stock    []LocationStock `json:"stock"`
+	Total    int             `json:"total"`
+	Options  []ProductOption `json:"options"`
+	Variants []VariantDetail `json:"variants"`
+}
+
 type ProductService struct {
 	DB *gorm.DB
 }
@@ -45,48 +58,190 @@ func (p ProductService) GetProductById(id uuid.UUID) (product *Product, err erro
 	return &item, nil
 }
 
-func (p ProductService) CreateProduct(data *data.AddProductParams) (*Product, error) {
+func (p ProductService) GetProductDetail(id uuid.UUID) (*ProductDetail, error) {
+	product, err := p.GetProductById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	stock, err := p.GetProductStock(id)
+	if err != nil {
+		return nil, err
+	}
+
+	total := 0
+	for _, level := range stock {
+		total = total + level.Quantity
+	}
+
+	options, err := productOptions(p.DB, id)
+	if err != nil {
+		return nil, err
+	}
+
+	variants, err := p.getVariantDetails(product, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ProductDetail{
+		Product:  *product,
+		Stock:    stock,
+		Total:    total,
+		Options:  options,
+		Variants: variants,
+	}, nil
+}
 
+func validateProduct(data *data.AddProductParams) error {
 	if len(data.Name) < 3 {
-		return nil, errors.New("invalid product name")
+		return errors.New("invalid product name")
 	}
 
-	var category = Category{}
-	if result := p.DB.Where("id = ?", data.CategoryId).First(&category); result.Error != nil {
-		return nil, errors.New("category id does not exist")
+	if data.Quantity < 0 {
+		return errors.New("quantity cannot be negative")
+	}
+
+	if data.ReorderPoint < 0 || data.ReorderQuantity < 0 {
+		return errors.New("reorder levels cannot be negative")
 	}
 
+	if data.CostPrice < 0 {
+		return errors.New("cost price cannot be negative")
+	}
+
+	return validateCodes(data.SKU, data.Barcode)
+}
+
+// insertProduct creates a validated product and books its opening stock.
+func insertProduct(tx *gorm.DB, data *data.AddProductParams, userId uuid.UUID) (*Product, error) {
 	product := Product{
-		ID:          uuid.New(),
-		Name:        data.Name,
-		Description: data.Description,
-		Quantity:    data.Quantity,
-		Price:       data.Price,
-		Image:       data.Image,
-		CategoryId:  data.CategoryId,
-		Slug:        utils.GenerateSlugs(data.Name),
+		ID:              uuid.New(),
+		Name:            data.Name,
+		Description:     data.Description,
+		Price:           data.Price,
+		CostPrice:       data.CostPrice,
+		Image:           data.Image,
+		CategoryId:      data.CategoryId,
+		Slug:            utils.GenerateSlugs(data.Name),
+		SKU:             data.SKU,
+		Barcode:         data.Barcode,
+		ReorderPoint:    data.ReorderPoint,
+		ReorderQuantity: data.ReorderQuantity,
+	}
+
+	if err := ensureUniqueCodes(tx, product.ID, uuid.Nil, product.SKU, product.Barcode); err != nil {
+		return nil, err
 	}
 
-	if result := p.DB.Create(&product); result.Error != nil {
+	if result := tx.Create(&product); result.Error != nil {
 		return nil, result.Error
 	}
 
+	level, err := applyStockChange(tx, stockChange{
+		ProductID:   product.ID,
+		LocationID:  data.LocationID,
+		Delta:       data.Quantity,
+		Reason:      MovementRestock,
+		ReferenceID: product.ID,
+		UserID:      userId,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	product.Quantity = level.Product.Quantity
 	return &product, nil
 }
 
-func (p ProductService) GetAllProducts() (*[]Product, error) {
+func (p ProductService) CreateProduct(data *data.AddProductParams, userId uuid.UUID) (*Product, error) {
+	if err := validateProduct(data); err != nil {
+		return nil, err
+	}
+
+	var category = Category{}
+	if result := p.DB.Where("id = ?", data.CategoryId).First(&category); result.Error != nil {
+		return nil, errors.New("category id does not exist")
+	}
+
+	var product *Product
+
+	err := stockTransaction(p.DB, func(tx *gorm.DB) error {
+		var err error
+		product, err = insertProduct(tx, data, userId)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return product, nil
+}
+
+var productSort = listSort{
+	Columns: map[string]string{
+		"name":       "name",
+		"price":      "price",
+		"quantity":   "quantity",
+		"created_at": "created_at",
+	},
+	DefaultKey:   "name",
+	DefaultOrder: "asc",
+}
+
+func productQuery(db *gorm.DB, filter data.ProductFilter) *gorm.DB {
+	query := db.Model(&Product{})
+
+	if filter.CategoryID != uuid.Nil {
+		query = query.Where("category_id = ?", filter.CategoryID)
+	}
+
+	if filter.MinPrice != nil {
+		query = query.Where("price >= ?", *filter.MinPrice)
+	}
+
+	if filter.MaxPrice != nil {
+		query = query.Where("price <= ?", *filter.MaxPrice)
+	}
+
+	if filter.InStock != nil {
+		if *filter.InStock {
+			query = query.Where("quantity > 0")
+		} else {
+			query = query.Where("quantity <= 0")
+		}
+	}
+
+	return query
+}
+
+func (p ProductService) GetAllProducts(filter data.ProductFilter) ([]Product, *ListMeta, error) {
 	var products []Product
 
-	if result := p.DB.Find(&products); result.Error != nil {
+	meta, err := paginate(productQuery(p.DB, filter), filter.ListParams, productSort, &products)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return products, meta, nil
+}
+
+func (p ProductService) GetLowStockProducts() ([]Product, error) {
+	var products []Product
+
+	result := p.DB.Where("reorder_point > 0 AND quantity <= reorder_point").Order("quantity asc").Find(&products)
+	if result.Error != nil {
 		return nil, result.Error
 	}
 
-	return &products, nil
+	return products, nil
 }
 
-func (p ProductService) UpdateProduct(id uuid.UUID, data *data.AddProductParams) error {
-	if len(data.Name) < 3 {
-		return errors.New("invalid product name")
+func (p ProductService) UpdateProduct(id uuid.UUID, data *data.AddProductParams, userId uuid.UUID) error {
+	if err := validateProduct(data); err != nil {
+		return err
 	}
 
 	product, err := p.GetProductById(id)
@@ -96,16 +251,46 @@ func (p ProductService) UpdateProduct(id uuid.UUID, data *data.AddProductParams)
 
 	product.Name = data.Name
 	product.Description = data.Description
-	product.Quantity = data.Quantity
 	product.Price = data.Price
 	product.Image = data.Image
 	product.Slug = utils.GenerateSlugs(data.Name)
+	product.ReorderPoint = data.ReorderPoint
+	product.ReorderQuantity = data.ReorderQuantity
+	product.CostPrice = data.CostPrice
+	product.SKU = data.SKU
+	product.Barcode = data.Barcode
+
+	return stockTransaction(p.DB, func(tx *gorm.DB) error {
+		if err := ensureUniqueCodes(tx, id, uuid.Nil, product.SKU, product.Barcode); err != nil {
+			return err
+		}
+
+		if result := tx.Omit("quantity").Save(product); result.Error != nil {
+			return result.Error
+		}
+
+		//Stock for products with variants is edited per variant
+		hasVariants, err := productHasVariants(tx, id)
+		if err != nil || hasVariants {
+			return err
+		}
+
+		current, err := applyStockChange(tx, stockChange{ProductID: id, LocationID: data.LocationID})
+		if err != nil {
+			return err
+		}
+
+		_, err = applyStockChange(tx, stockChange{
+			ProductID:   id,
+			LocationID:  current.LocationID,
+			Delta:       data.Quantity - current.Available,
+			Reason:      MovementAdjustment,
+			ReferenceID: id,
+			UserID:      userId,
+		})
 
-	if result := p.DB.Save(&product); result.Error != nil {
-		return result.Error
-	}
-
-	return nil
+		return err
+	})
 }
 
 func (p ProductService) DeleteProduct(id uuid.UUID) error {
@@ -122,38 +307,66 @@ func (p ProductService) DeleteProduct(id uuid.UUID) error {
 	return nil
 }
 
-func (p ProductService) IncrementQuantity(id uuid.UUID) (int, error) {
-	product, err := p.GetProductById(id)
+func (p ProductService) IncrementQuantity(id uuid.UUID, locationID uuid.UUID, userId uuid.UUID) (int, error) {
+	var quantity int
 
-	if err != nil {
-		return 0, err
-	}
+	err := stockTransaction(p.DB, func(tx *gorm.DB) error {
+		level, err := applyStockChange(tx, stockChange{
+			ProductID:  id,
+			LocationID: locationID,
+			Delta:      1,
+			Reason:     MovementRestock,
+			UserID:     userId,
+		})
 
-	product.Quantity = product.Quantity + 1
+		if err != nil {
+			return err
+		}
 
-	if result := p.DB.Save(&product); result.Error != nil {
+		quantity = level.Available
+		return nil
+	})
+
+	if err != nil {
 		return 0, err
 	}
 
-	return product.Quantity, nil
+	return quantity, nil
 }
 
-func (p ProductService) DecreaseQuantity(id uuid.UUID) (int, error) {
-	product, err := p.GetProductById(id)
+func (p ProductService) DecreaseQuantity(id uuid.UUID, locationID uuid.UUID, userId uuid.UUID) (int, error) {
+	var quantity int
 
-	if err != nil {
-		return 0, err
-	}
+	err := stockTransaction(p.DB, func(tx *gorm.DB) error {
+		level, err := applyStockChange(tx, stockChange{ProductID: id, LocationID: locationID})
 
-	if product.Quantity == 0 {
-		return product.Quantity, nil
-	}
+		if err != nil {
+			return err
+		}
+
+		if level.Available == 0 {
+			return nil
+		}
+
+		level, err = applyStockChange(tx, stockChange{
+			ProductID:  id,
+			LocationID: level.LocationID,
+			Delta:      -1,
+			Reason:     MovementAdjustment,
+			UserID:     userId,
+		})
 
-	product.Quantity = product.Quantity - 1
+		if err != nil {
+			return err
+		}
 
-	if result := p.DB.Save(&product); result.Error != nil {
+		quantity = level.Available
+		return nil
+	})
+
+	if err != nil {
 		return 0, err
 	}
 
-	return product.Quantity, nil
+	return quantity, nil
 }
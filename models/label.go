@@ -0,0 +1,76 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/loyalsfc/investrite/data"
+)
+
+const maxLabels = 500
+
+type LabelItem struct {
+	Name    string
+	Price   int
+	SKU     string
+	Barcode string
+	Copies  int
+}
+
+// GetLabelItems resolves the products and variants to print along with how
+// many copies of each are wanted, so every label is only rendered once.
+func (p ProductService) GetLabelItems(params []data.LabelItemParams) ([]LabelItem, error) {
+	if len(params) == 0 {
+		return nil, errors.New("no products selected")
+	}
+
+	var items []LabelItem
+	total := 0
+
+	for _, param := range params {
+		copies := param.Copies
+		if copies < 1 {
+			copies = 1
+		}
+
+		if copies > maxLabels-total {
+			return nil, fmt.Errorf("cannot print more than %v labels at once", maxLabels)
+		}
+
+		product, err := p.GetProductById(param.ProductID)
+		if err != nil {
+			return nil, fmt.Errorf("no product found for id %v", param.ProductID)
+		}
+
+		item := LabelItem{
+			Name:    product.Name,
+			Price:   product.Price,
+			SKU:     product.SKU,
+			Barcode: product.Barcode,
+			Copies:  copies,
+		}
+
+		if param.VariantID != uuid.Nil {
+			variant, err := p.GetVariant(product.ID, param.VariantID)
+			if err != nil {
+				return nil, fmt.Errorf("variant %v does not belong to %v", param.VariantID, product.Name)
+			}
+
+			options, err := productOptions(p.DB, product.ID)
+			if err != nil {
+				return nil, err
+			}
+
+			item.Name = product.Name + " (" + variant.Options.Label(options) + ")"
+			item.Price = variant.EffectivePrice(product)
+			item.SKU = variant.SKU
+			item.Barcode = variant.Barcode
+		}
+
+		items = append(items, item)
+		total = total + copies
+	}
+
+	return items, nil
+}
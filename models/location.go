@@ -0,0 +1,293 @@
+package models
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/loyalsfc/investrite/data"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Location struct {
+	ID        uuid.UUID `json:"id" gorm:"column:id;unique;not null"`
+	Name      string    `json:"name" gorm:"column:name;unique;not null"`
+	Address   string    `json:"address" gorm:"column:address"`
+	IsDefault bool      `json:"is_default" gorm:"column:is_default;default:false;not null"`
+	gorm.Model
+}
+
+type ProductStock struct {
+	ID         uuid.UUID `json:"id" gorm:"column:id;unique;not null"`
+	ProductID  uuid.UUID `json:"product_id" gorm:"column:product_id;uniqueIndex:idx_product_variant_location;not null"`
+	VariantID  uuid.UUID `json:"variant_id" gorm:"column:variant_id;uniqueIndex:idx_product_variant_location;not null;default:'00000000-0000-0000-0000-000000000000'"`
+	LocationID uuid.UUID `json:"location_id" gorm:"column:location_id;uniqueIndex:idx_product_variant_location;not null"`
+	Quantity   int       `json:"quantity" gorm:"column:quantity;default:0;not null"`
+	gorm.Model
+}
+
+type LocationStock struct {
+	LocationID   uuid.UUID `json:"location_id"`
+	LocationName string    `json:"location_name"`
+	ProductID    uuid.UUID `json:"product_id,omitempty"`
+	ProductName  string    `json:"product_name,omitempty"`
+	Quantity     int       `json:"quantity"`
+}
+
+type LocationService struct {
+	DB *gorm.DB
+}
+
+func defaultLocationID(tx *gorm.DB) (uuid.UUID, error) {
+	var ids []uuid.UUID
+
+	if result := tx.Model(&Location{}).Where("is_default = ?", true).Limit(1).Pluck("id", &ids); result.Error != nil {
+		return uuid.Nil, result.Error
+	}
+
+	if len(ids) == 0 {
+		return uuid.Nil, errors.New("no default location configured")
+	}
+
+	return ids[0], nil
+}
+
+func resolveLocationID(tx *gorm.DB, id uuid.UUID) (uuid.UUID, error) {
+	if id == uuid.Nil {
+		return defaultLocationID(tx)
+	}
+
+	if result := tx.Where("id = ?", id).First(&Location{}); result.Error != nil {
+		return uuid.Nil, errors.New("location id does not exist")
+	}
+
+	return id, nil
+}
+
+// lockProductStock returns the stock row for a product (or one of its
+// variants) at a location, creating an empty one on first use. Callers hold
+// the product row lock, so two transactions cannot race to create the same row.
+func lockProductStock(tx *gorm.DB, productID uuid.UUID, variantID uuid.UUID, locationID uuid.UUID) (*ProductStock, error) {
+	var stock ProductStock
+
+	result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("product_id = ? AND variant_id = ? AND location_id = ?", productID, variantID, locationID).
+		Limit(1).
+		Find(&stock)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected > 0 {
+		return &stock, nil
+	}
+
+	stock = ProductStock{
+		ID:         uuid.New(),
+		ProductID:  productID,
+		VariantID:  variantID,
+		LocationID: locationID,
+	}
+
+	if result := tx.Create(&stock); result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &stock, nil
+}
+
+func (l LocationService) CreateLocation(param data.LocationParams) (*Location, error) {
+	if len(param.Name) < 3 {
+		return nil, errors.New("location name cannot be less than 3")
+	}
+
+	if result := l.DB.Where("name = ?", param.Name).First(&Location{}); result.Error == nil {
+		return nil, errors.New("location already exist")
+	}
+
+	location := Location{
+		ID:      uuid.New(),
+		Name:    param.Name,
+		Address: param.Address,
+	}
+
+	if result := l.DB.Create(&location); result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &location, nil
+}
+
+func (l LocationService) GetLocations() ([]Location, error) {
+	var locations []Location
+
+	if result := l.DB.Order("name asc").Find(&locations); result.Error != nil {
+		return nil, result.Error
+	}
+
+	return locations, nil
+}
+
+func (l LocationService) GetLocationById(id uuid.UUID) (*Location, error) {
+	var location Location
+
+	if result := l.DB.Where("id = ?", id).First(&location); result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &location, nil
+}
+
+func (l LocationService) UpdateLocation(id uuid.UUID, param data.LocationParams) error {
+	if len(param.Name) < 3 {
+		return errors.New("location name cannot be less than 3")
+	}
+
+	location, err := l.GetLocationById(id)
+	if err != nil {
+		return err
+	}
+
+	location.Name = param.Name
+	location.Address = param.Address
+
+	if result := l.DB.Save(location); result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
+
+func (l LocationService) SetDefaultLocation(id uuid.UUID) error {
+	if _, err := l.GetLocationById(id); err != nil {
+		return err
+	}
+
+	return l.DB.Transaction(func(tx *gorm.DB) error {
+		if result := tx.Model(&Location{}).Where("is_default = ?", true).Update("is_default", false); result.Error != nil {
+			return result.Error
+		}
+
+		return tx.Model(&Location{}).Where("id = ?", id).Update("is_default", true).Error
+	})
+}
+
+func (l LocationService) DeleteLocation(id uuid.UUID) error {
+	location, err := l.GetLocationById(id)
+	if err != nil {
+		return err
+	}
+
+	if location.IsDefault {
+		return errors.New("the default location cannot be deleted")
+	}
+
+	var stocked int64
+	if result := l.DB.Model(&ProductStock{}).Where("location_id = ? AND quantity <> 0", id).Count(&stocked); result.Error != nil {
+		return result.Error
+	}
+
+	if stocked > 0 {
+		return errors.New("location still holds stock")
+	}
+
+	if result := l.DB.Where("id = ?", id).Delete(&Location{}); result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
+
+func (l LocationService) GetLocationStock(id uuid.UUID) ([]LocationStock, error) {
+	location, err := l.GetLocationById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var stock []LocationStock
+
+	result := l.DB.Model(&ProductStock{}).
+		Select("product_stocks.product_id, products.name AS product_name, SUM(product_stocks.quantity) AS quantity").
+		Joins("JOIN products ON products.id = product_stocks.product_id AND products.deleted_at IS NULL").
+		Where("product_stocks.location_id = ?", id).
+		Group("product_stocks.product_id, products.name").
+		Order("products.name asc").
+		Scan(&stock)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	for i := range stock {
+		stock[i].LocationID = location.ID
+		stock[i].LocationName = location.Name
+	}
+
+	return stock, nil
+}
+
+func (p ProductService) GetProductStock(id uuid.UUID) ([]LocationStock, error) {
+	var stock []LocationStock
+
+	result := p.DB.Model(&ProductStock{}).
+		Select("product_stocks.location_id, locations.name AS location_name, SUM(product_stocks.quantity) AS quantity").
+		Joins("JOIN locations ON locations.id = product_stocks.location_id").
+		Where("product_stocks.product_id = ?", id).
+		Group("product_stocks.location_id, locations.name").
+		Order("locations.name asc").
+		Scan(&stock)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return stock, nil
+}
+
+// MigrateToLocations creates the default location on first run and moves
+// stock recorded before locations existed into it.
+func MigrateToLocations(db *gorm.DB) error {
+	locationID, err := defaultLocationID(db)
+
+	if err != nil {
+		location := Location{
+			ID:        uuid.New(),
+			Name:      "Main store",
+			IsDefault: true,
+		}
+
+		if result := db.Create(&location); result.Error != nil {
+			return result.Error
+		}
+
+		locationID = location.ID
+	}
+
+	var products []Product
+	result := db.Where("NOT EXISTS (SELECT 1 FROM product_stocks WHERE product_stocks.product_id = products.id)").Find(&products)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	for _, product := range products {
+		stock := ProductStock{
+			ID:         uuid.New(),
+			ProductID:  product.ID,
+			LocationID: locationID,
+			Quantity:   product.Quantity,
+		}
+
+		if result := db.Create(&stock); result.Error != nil {
+			return result.Error
+		}
+	}
+
+	for _, model := range []interface{}{&StockMovement{}, &Order{}, &PurchaseReceipt{}, &Stocktake{}} {
+		result := db.Unscoped().Model(model).
+			Where("location_id IS NULL OR location_id = ?", uuid.Nil).
+			Update("location_id", locationID)
+		if result.Error != nil {
+			return result.Error
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/loyalsfc/investrite/utils"
+	"gorm.io/gorm"
+)
+
+type CodeMatch struct {
+	Product *Product        `json:"product"`
+	Variant *ProductVariant `json:"variant"`
+	Price   int             `json:"price"`
+}
+
+func validateCodes(sku string, barcode string) error {
+	if sku != "" && strings.ContainsAny(sku, " \t\n") {
+		return errors.New("sku cannot contain whitespace")
+	}
+
+	if barcode != "" && !utils.IsValidBarcode(barcode) {
+		return fmt.Errorf("%v is not a valid EAN-13 or UPC-A barcode", barcode)
+	}
+
+	return nil
+}
+
+// ensureUniqueCodes rejects a SKU or barcode that is already used by another
+// product or variant, in either column, so a scanned code resolves to exactly
+// one item. productID and variantID identify the record being saved.
+func ensureUniqueCodes(tx *gorm.DB, productID uuid.UUID, variantID uuid.UUID, codes ...string) error {
+	for _, code := range codes {
+		if code == "" {
+			continue
+		}
+
+		var count int64
+		result := tx.Model(&Product{}).
+			Where("(sku = ? OR barcode = ?) AND id <> ?", code, code, productID).
+			Count(&count)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if count == 0 {
+			result = tx.Model(&ProductVariant{}).
+				Where("(sku = ? OR barcode = ?) AND id <> ?", code, code, variantID).
+				Count(&count)
+			if result.Error != nil {
+				return result.Error
+			}
+		}
+
+		if count > 0 {
+			return fmt.Errorf("code %v is already in use", code)
+		}
+	}
+
+	return nil
+}
+
+func lookupCode(tx *gorm.DB, code string) (*CodeMatch, error) {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return nil, errors.New("no code provided")
+	}
+
+	var variant ProductVariant
+	result := tx.Where("sku = ? OR barcode = ?", code, code).Limit(1).Find(&variant)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected > 0 {
+		var product Product
+		if result := tx.Where("id = ?", variant.ProductID).First(&product); result.Error != nil {
+			return nil, result.Error
+		}
+
+		return &CodeMatch{Product: &product, Variant: &variant, Price: variant.EffectivePrice(&product)}, nil
+	}
+
+	var product Product
+	result = tx.Where("sku = ? OR barcode = ?", code, code).Limit(1).Find(&product)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("no product found for code %v", code)
+	}
+
+	return &CodeMatch{Product: &product, Price: product.Price}, nil
+}
+
+func (p ProductService) LookupCode(code string) (*CodeMatch, error) {
+	return lookupCode(p.DB, code)
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
 
 	"github.com/google/uuid"
 	"github.com/loyalsfc/investrite/data"
@@ -13,17 +14,36 @@ import (
 type Status string
 
 const (
-	pending   Status = "pending"
-	completed Status = "completed"
-	failed    Status = "failed"
+	pending           Status = "pending"
+	completed         Status = "completed"
+	failed            Status = "failed"
+	refunded          Status = "refunded"
+	partiallyRefunded Status = "partially_refunded"
 )
 
 type Order struct {
 	OrderID       uuid.UUID          `json:"order_id" gorm:"column:order_id;unique;primary;not null"`
 	Status        Status             `json:"status" gorm:"column:status;not null"`
-	Product       json.RawMessage    `json:"products" gorm:"foreignKey:product_id;column:products;type:jsonb;not null"`
+	Items         []OrderItem        `json:"items" gorm:"foreignKey:OrderID;references:OrderID"`
+	Refunds       []Refund           `json:"refunds" gorm:"foreignKey:OrderID;references:OrderID"`
 	PaymentMethod data.PaymentMethod `json:"payment_method" gorm:"column:payment_method;embedded;not null"`
 	TotalPrice    int                `json:"total_price" gorm:"column:total_price;not null"`
+	LocationID    uuid.UUID          `json:"location_id" gorm:"column:location_id;index"`
+	gorm.Model
+}
+
+type OrderItem struct {
+	ID               uuid.UUID `json:"id" gorm:"column:id;unique;not null"`
+	OrderID          uuid.UUID `json:"order_id" gorm:"column:order_id;index;not null"`
+	ProductID        uuid.UUID `json:"product_id" gorm:"column:product_id;index;not null"`
+	VariantID        uuid.UUID `json:"variant_id" gorm:"column:variant_id"`
+	ProductName      string    `json:"product_name" gorm:"column:product_name;not null"`
+	VariantName      string    `json:"variant_name" gorm:"column:variant_name"`
+	UnitPrice        int       `json:"unit_price" gorm:"column:unit_price;not null"`
+	Quantity         int       `json:"quantity" gorm:"column:quantity;not null"`
+	LineTotal        int       `json:"line_total" gorm:"column:line_total;not null"`
+	CostTotal        int       `json:"cost_total" gorm:"column:cost_total;default:0;not null"`
+	RefundedQuantity int       `json:"refunded_quantity" gorm:"column:refunded_quantity;default:0;not null"`
 	gorm.Model
 }
 
@@ -31,7 +51,7 @@ type OrderService struct {
 	DB *gorm.DB
 }
 
-func (o OrderService) CreateOrder(param data.OrderParams) (*Order, error) {
+func (o OrderService) CreateOrder(param data.OrderParams, userId uuid.UUID) (*Order, error) {
 	//calculate the amount paid
 	amountPaid := param.PaymentMethod.Cash + param.PaymentMethod.Pos + param.PaymentMethod.Transfer
 
@@ -39,62 +59,210 @@ func (o OrderService) CreateOrder(param data.OrderParams) (*Order, error) {
 		return nil, errors.New("please include a valid payment method")
 	}
 
-	// Calculate total order price
-	totalPrice := 0
+	if len(param.Products) == 0 {
+		return nil, errors.New("order must contain at least one product")
+	}
 
-	//Check if all products are available and the quantity required
+	//Merge repeated lines so each product or variant is locked and deducted once
+	quantities := map[stockKey]int{}
 	for _, product := range param.Products {
 		if product.Quantity < 1 {
 			return nil, fmt.Errorf("invalid quantity for product with id %v", product.ProductID)
 		}
 
-		var item Product
-		result := o.DB.Where("id = ?", product.ProductID).First(&item)
-		if result.Error != nil {
-			return nil, fmt.Errorf("no product found for id %v", product.ProductID)
+		key := stockKey{ProductID: product.ProductID, VariantID: product.VariantID}
+
+		//Scanned lines carry a barcode or SKU instead of ids
+		if key.ProductID == uuid.Nil && product.Code != "" {
+			match, err := lookupCode(o.DB, product.Code)
+			if err != nil {
+				return nil, err
+			}
+
+			key.ProductID = match.Product.ID
+			if match.Variant != nil {
+				key.VariantID = match.Variant.ID
+			}
 		}
 
-		totalPrice = totalPrice + (item.Price * product.Quantity)
+		quantities[key] = quantities[key] + product.Quantity
 	}
 
-	if totalPrice != amountPaid {
-		return nil, errors.New("total amount does not match")
-	}
-
-	marshal, err := json.Marshal(param.Products)
-
-	if err != nil {
-		return nil, err
+	//Lock rows in a fixed order so concurrent orders cannot deadlock
+	keys := make([]stockKey, 0, len(quantities))
+	for key := range quantities {
+		keys = append(keys, key)
 	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
 
 	order := Order{
 		OrderID:       uuid.New(),
 		Status:        completed,
-		Product:       marshal,
 		PaymentMethod: param.PaymentMethod,
-		TotalPrice:    totalPrice,
 	}
 
-	if result := o.DB.Create(&order); result.Error != nil {
-		return nil, result.Error
+	err := stockTransaction(o.DB, func(tx *gorm.DB) error {
+		locationID, err := resolveLocationID(tx, param.LocationID)
+		if err != nil {
+			return err
+		}
+		order.LocationID = locationID
+
+		// Calculate total order price
+		totalPrice := 0
+
+		for _, key := range keys {
+			level, err := applyStockChange(tx, stockChange{
+				ProductID:  key.ProductID,
+				VariantID:  key.VariantID,
+				LocationID: locationID,
+			})
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("no product found for id %v", key.ProductID)
+			}
+			if err != nil {
+				return err
+			}
+
+			item := OrderItem{
+				ID:          uuid.New(),
+				OrderID:     order.OrderID,
+				ProductID:   level.Product.ID,
+				ProductName: level.Product.Name,
+				UnitPrice:   level.Product.Price,
+				Quantity:    quantities[key],
+			}
+
+			if level.Variant != nil {
+				options, err := productOptions(tx, level.Product.ID)
+				if err != nil {
+					return err
+				}
+
+				item.VariantID = level.Variant.ID
+				item.VariantName = level.Variant.Options.Label(options)
+				item.UnitPrice = level.Variant.EffectivePrice(level.Product)
+			} else {
+				hasVariants, err := productHasVariants(tx, level.Product.ID)
+				if err != nil {
+					return err
+				}
+
+				if hasVariants {
+					return fmt.Errorf("%v has variants, specify a variant_id", level.Product.Name)
+				}
+			}
+
+			if level.Available < item.Quantity {
+				return fmt.Errorf("insufficient stock for %v, %v available", level.Product.Name, level.Available)
+			}
+
+			item.LineTotal = item.UnitPrice * item.Quantity
+			totalPrice = totalPrice + item.LineTotal
+
+			order.Items = append(order.Items, item)
+		}
+
+		if totalPrice != amountPaid {
+			return errors.New("total amount does not match")
+		}
+
+		for i, key := range keys {
+			level, err := applyStockChange(tx, stockChange{
+				ProductID:   key.ProductID,
+				VariantID:   key.VariantID,
+				LocationID:  locationID,
+				Delta:       -quantities[key],
+				Reason:      MovementSale,
+				ReferenceID: order.OrderID,
+				UserID:      userId,
+			})
+
+			if err != nil {
+				return err
+			}
+
+			order.Items[i].CostTotal = level.Cost
+		}
+
+		order.TotalPrice = totalPrice
+
+		if result := tx.Create(&order); result.Error != nil {
+			return result.Error
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
 	}
 
 	return &order, nil
 }
 
-func (o OrderService) GetAllOrders() ([]Order, error) {
+var orderSort = listSort{
+	Columns: map[string]string{
+		"created_at":  "orders.created_at",
+		"total_price": "orders.total_price",
+	},
+	DefaultKey:   "created_at",
+	DefaultOrder: "desc",
+}
+
+// orderQuery qualifies every column so it can be joined with order_items.
+func orderQuery(db *gorm.DB, filter data.OrderFilter) (*gorm.DB, error) {
+	query := db.Model(&Order{})
+
+	if !filter.From.IsZero() {
+		query = query.Where("orders.created_at >= ?", filter.From)
+	}
+
+	//The end date is inclusive, so match anything before the following day
+	if !filter.To.IsZero() {
+		query = query.Where("orders.created_at < ?", filter.To.AddDate(0, 0, 1))
+	}
+
+	if filter.Status != "" {
+		query = query.Where("orders.status = ?", filter.Status)
+	}
+
+	switch filter.PaymentMethod {
+	case "":
+	case "cash", "transfer", "pos":
+		query = query.Where("orders." + filter.PaymentMethod + " > 0")
+	default:
+		return nil, fmt.Errorf("unknown payment method %v", filter.PaymentMethod)
+	}
+
+	if filter.LocationID != uuid.Nil {
+		query = query.Where("orders.location_id = ?", filter.LocationID)
+	}
+
+	return query, nil
+}
+
+func (o OrderService) GetAllOrders(filter data.OrderFilter) ([]Order, *ListMeta, error) {
 	var orders []Order
 
-	if result := o.DB.Find(&orders); result.Error != nil {
-		return nil, result.Error
+	query, err := orderQuery(o.DB, filter)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return orders, nil
+	meta, err := paginate(query.Preload("Items"), filter.ListParams, orderSort, &orders)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return orders, meta, nil
 }
 
 func (o OrderService) FindOrder(id uuid.UUID) (*Order, error) {
 	var order Order
-	if result := o.DB.Where("order_id = ?", id).First(&order); result.Error != nil {
+	if result := o.DB.Preload("Items").Preload("Refunds.Items").Where("order_id = ?", id).First(&order); result.Error != nil {
 		return nil, result.Error
 	}
 
@@ -108,3 +276,66 @@ func (o OrderService) DeleteOrder(id uuid.UUID) error {
 
 	return nil
 }
+
+// MigrateOrderItems converts orders that still hold their lines in the legacy
+// products JSONB column into order_items rows, then drops the column. Prices
+// are taken from the current product since the JSONB blob never stored them.
+// Orders that already have items are skipped, so an interrupted run picks up
+// where it stopped.
+func MigrateOrderItems(db *gorm.DB) error {
+	if !db.Migrator().HasColumn("orders", "products") {
+		return nil
+	}
+
+	var orders []struct {
+		OrderID  uuid.UUID
+		Products string
+	}
+
+	result := db.Table("orders").
+		Select("order_id, products").
+		Where("products IS NOT NULL AND NOT EXISTS (SELECT 1 FROM order_items WHERE order_items.order_id = orders.order_id)").
+		Scan(&orders)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	for _, order := range orders {
+		var lines []data.OrderProducts
+
+		if err := json.Unmarshal([]byte(order.Products), &lines); err != nil {
+			return fmt.Errorf("order %v has unreadable products: %v", order.OrderID, err)
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			for _, line := range lines {
+				var product Product
+				if result := tx.Unscoped().Where("id = ?", line.ProductID).First(&product); result.Error != nil {
+					product = Product{ID: line.ProductID, Name: "unknown product"}
+				}
+
+				item := OrderItem{
+					ID:          uuid.New(),
+					OrderID:     order.OrderID,
+					ProductID:   line.ProductID,
+					ProductName: product.Name,
+					UnitPrice:   product.Price,
+					Quantity:    line.Quantity,
+					LineTotal:   product.Price * line.Quantity,
+				}
+
+				if result := tx.Create(&item); result.Error != nil {
+					return result.Error
+				}
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return db.Migrator().DropColumn("orders", "products")
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestMigrateOrderItemsBackfillsThenDropsLegacyColumn(t *testing.T) {
+	orderID, productID := uuid.New(), uuid.New()
+
+	db, fake := newFakeDB(t, func(query string, args []driver.Value) *fakeRows {
+		switch {
+		case strings.Contains(query, "INFORMATION_SCHEMA.columns"):
+			return &fakeRows{Columns: []string{"count"}, Values: [][]driver.Value{{int64(1)}}}
+		case strings.Contains(query, `SELECT order_id, products FROM "orders"`):
+			return &fakeRows{
+				Columns: []string{"order_id", "products"},
+				Values:  [][]driver.Value{{orderID.String(), `[{"product_id":"` + productID.String() + `","quantity":3}]`}},
+			}
+		case strings.Contains(query, `FROM "products"`):
+			return &fakeRows{
+				Columns: []string{"id", "name", "price"},
+				Values:  [][]driver.Value{{productID.String(), "rice", int64(250)}},
+			}
+		}
+
+		return nil
+	})
+
+	if err := MigrateOrderItems(db); err != nil {
+		t.Fatalf("MigrateOrderItems: %v", err)
+	}
+
+	items := fake.Matching(`INSERT INTO "order_items"`)
+	if len(items) != 1 {
+		t.Fatalf("got %d order item inserts, want 1", len(items))
+	}
+
+	if !hasArg(items[0].Args, orderID.String()) || !hasArg(items[0].Args, int64(750)) {
+		t.Errorf("order item args %v, want order %v with a line total of 750", items[0].Args, orderID)
+	}
+
+	if got := len(fake.Matching(`DROP COLUMN "products"`)); got != 1 {
+		t.Errorf("got %d drops of the legacy column, want 1", got)
+	}
+
+	if got := len(fake.Matching("DROP NOT NULL")); got != 0 {
+		t.Errorf("legacy column was altered %d times, want it left alone until dropped", got)
+	}
+}
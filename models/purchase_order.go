@@ -0,0 +1,326 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/loyalsfc/investrite/data"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PurchaseOrderStatus string
+
+const (
+	PODraft             PurchaseOrderStatus = "draft"
+	POSubmitted         PurchaseOrderStatus = "submitted"
+	POPartiallyReceived PurchaseOrderStatus = "partially_received"
+	POReceived          PurchaseOrderStatus = "received"
+	POCancelled         PurchaseOrderStatus = "cancelled"
+)
+
+type PurchaseOrder struct {
+	ID         uuid.UUID           `json:"id" gorm:"column:id;unique;not null"`
+	SupplierID uuid.UUID           `json:"supplier_id" gorm:"column:supplier_id;index;not null"`
+	Status     PurchaseOrderStatus `json:"status" gorm:"column:status;not null"`
+	Reference  string              `json:"reference" gorm:"column:reference"`
+	Note       string              `json:"note" gorm:"column:note"`
+	CreatedBy  uuid.UUID           `json:"created_by" gorm:"column:created_by;not null"`
+	Lines      []PurchaseOrderLine `json:"lines" gorm:"foreignKey:PurchaseOrderID;references:ID"`
+	Receipts   []PurchaseReceipt   `json:"receipts" gorm:"foreignKey:PurchaseOrderID;references:ID"`
+	gorm.Model
+}
+
+type PurchaseOrderLine struct {
+	ID               uuid.UUID `json:"id" gorm:"column:id;unique;not null"`
+	PurchaseOrderID  uuid.UUID `json:"purchase_order_id" gorm:"column:purchase_order_id;index;not null"`
+	ProductID        uuid.UUID `json:"product_id" gorm:"column:product_id;not null"`
+	VariantID        uuid.UUID `json:"variant_id" gorm:"column:variant_id"`
+	QuantityOrdered  int       `json:"quantity_ordered" gorm:"column:quantity_ordered;not null"`
+	QuantityReceived int       `json:"quantity_received" gorm:"column:quantity_received;default:0;not null"`
+	UnitCost         int       `json:"unit_cost" gorm:"column:unit_cost;not null"`
+	gorm.Model
+}
+
+type PurchaseReceipt struct {
+	ID              uuid.UUID `json:"id" gorm:"column:id;unique;not null"`
+	PurchaseOrderID uuid.UUID `json:"purchase_order_id" gorm:"column:purchase_order_id;index;not null"`
+	LineID          uuid.UUID `json:"line_id" gorm:"column:line_id;not null"`
+	ProductID       uuid.UUID `json:"product_id" gorm:"column:product_id;index;not null"`
+	VariantID       uuid.UUID `json:"variant_id" gorm:"column:variant_id"`
+	LocationID      uuid.UUID `json:"location_id" gorm:"column:location_id"`
+	Quantity        int       `json:"quantity" gorm:"column:quantity;not null"`
+	UnitCost        int       `json:"unit_cost" gorm:"column:unit_cost;not null"`
+	UserID          uuid.UUID `json:"user_id" gorm:"column:user_id;not null"`
+	gorm.Model
+}
+
+type PurchaseOrderService struct {
+	DB *gorm.DB
+}
+
+func buildPurchaseOrderLines(tx *gorm.DB, orderID uuid.UUID, params []data.PurchaseOrderLineParams) ([]PurchaseOrderLine, error) {
+	if len(params) == 0 {
+		return nil, errors.New("purchase order must contain at least one line")
+	}
+
+	var lines []PurchaseOrderLine
+
+	for _, param := range params {
+		if param.Quantity < 1 {
+			return nil, fmt.Errorf("invalid quantity for product with id %v", param.ProductID)
+		}
+
+		if param.UnitCost < 0 {
+			return nil, fmt.Errorf("invalid unit cost for product with id %v", param.ProductID)
+		}
+
+		if result := tx.Where("id = ?", param.ProductID).First(&Product{}); result.Error != nil {
+			return nil, fmt.Errorf("no product found for id %v", param.ProductID)
+		}
+
+		if err := checkVariant(tx, param.ProductID, param.VariantID); err != nil {
+			return nil, err
+		}
+
+		lines = append(lines, PurchaseOrderLine{
+			ID:              uuid.New(),
+			PurchaseOrderID: orderID,
+			ProductID:       param.ProductID,
+			VariantID:       param.VariantID,
+			QuantityOrdered: param.Quantity,
+			UnitCost:        param.UnitCost,
+		})
+	}
+
+	return lines, nil
+}
+
+func (p PurchaseOrderService) CreatePurchaseOrder(param data.PurchaseOrderParams, userId uuid.UUID) (*PurchaseOrder, error) {
+	if result := p.DB.Where("id = ?", param.SupplierID).First(&Supplier{}); result.Error != nil {
+		return nil, errors.New("supplier id does not exist")
+	}
+
+	order := PurchaseOrder{
+		ID:         uuid.New(),
+		SupplierID: param.SupplierID,
+		Status:     PODraft,
+		Reference:  param.Reference,
+		Note:       param.Note,
+		CreatedBy:  userId,
+	}
+
+	lines, err := buildPurchaseOrderLines(p.DB, order.ID, param.Lines)
+	if err != nil {
+		return nil, err
+	}
+	order.Lines = lines
+
+	if result := p.DB.Create(&order); result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &order, nil
+}
+
+func (p PurchaseOrderService) GetPurchaseOrders() ([]PurchaseOrder, error) {
+	var orders []PurchaseOrder
+
+	if result := p.DB.Preload("Lines").Order("created_at desc").Find(&orders); result.Error != nil {
+		return nil, result.Error
+	}
+
+	return orders, nil
+}
+
+func (p PurchaseOrderService) GetPurchaseOrder(id uuid.UUID) (*PurchaseOrder, error) {
+	var order PurchaseOrder
+
+	if result := p.DB.Preload("Lines").Preload("Receipts").Where("id = ?", id).First(&order); result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &order, nil
+}
+
+func (p PurchaseOrderService) UpdatePurchaseOrder(id uuid.UUID, param data.PurchaseOrderParams) error {
+	return p.DB.Transaction(func(tx *gorm.DB) error {
+		var order PurchaseOrder
+
+		if result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&order); result.Error != nil {
+			return result.Error
+		}
+
+		if order.Status != PODraft {
+			return errors.New("only draft purchase orders can be edited")
+		}
+
+		if result := tx.Where("id = ?", param.SupplierID).First(&Supplier{}); result.Error != nil {
+			return errors.New("supplier id does not exist")
+		}
+
+		lines, err := buildPurchaseOrderLines(tx, id, param.Lines)
+		if err != nil {
+			return err
+		}
+
+		if result := tx.Unscoped().Where("purchase_order_id = ?", id).Delete(&PurchaseOrderLine{}); result.Error != nil {
+			return result.Error
+		}
+
+		if result := tx.Create(&lines); result.Error != nil {
+			return result.Error
+		}
+
+		result := tx.Model(&PurchaseOrder{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"supplier_id": param.SupplierID,
+			"reference":   param.Reference,
+			"note":        param.Note,
+		})
+
+		return result.Error
+	})
+}
+
+func (p PurchaseOrderService) changeStatus(id uuid.UUID, from []PurchaseOrderStatus, to PurchaseOrderStatus) error {
+	return p.DB.Transaction(func(tx *gorm.DB) error {
+		var order PurchaseOrder
+
+		if result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&order); result.Error != nil {
+			return result.Error
+		}
+
+		for _, status := range from {
+			if order.Status == status {
+				return tx.Model(&PurchaseOrder{}).Where("id = ?", id).Update("status", to).Error
+			}
+		}
+
+		return fmt.Errorf("cannot move purchase order from %v to %v", order.Status, to)
+	})
+}
+
+func (p PurchaseOrderService) SubmitPurchaseOrder(id uuid.UUID) error {
+	return p.changeStatus(id, []PurchaseOrderStatus{PODraft}, POSubmitted)
+}
+
+func (p PurchaseOrderService) CancelPurchaseOrder(id uuid.UUID) error {
+	return p.changeStatus(id, []PurchaseOrderStatus{PODraft, POSubmitted}, POCancelled)
+}
+
+func (p PurchaseOrderService) ReceivePurchaseOrder(id uuid.UUID, param data.ReceivePurchaseOrderParams, userId uuid.UUID) (*PurchaseOrder, error) {
+	if len(param.Lines) == 0 {
+		return nil, errors.New("no lines to receive")
+	}
+
+	err := stockTransaction(p.DB, func(tx *gorm.DB) error {
+		var order PurchaseOrder
+
+		if result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&order); result.Error != nil {
+			return result.Error
+		}
+
+		if order.Status != POSubmitted && order.Status != POPartiallyReceived {
+			return fmt.Errorf("cannot receive a purchase order that is %v", order.Status)
+		}
+
+		locationID, err := resolveLocationID(tx, param.LocationID)
+		if err != nil {
+			return err
+		}
+
+		if result := tx.Where("purchase_order_id = ?", id).Find(&order.Lines); result.Error != nil {
+			return result.Error
+		}
+
+		var changes []stockChange
+
+		for _, received := range param.Lines {
+			var line *PurchaseOrderLine
+			for i := range order.Lines {
+				if order.Lines[i].ID == received.LineID {
+					line = &order.Lines[i]
+				}
+			}
+
+			if line == nil {
+				return fmt.Errorf("line %v does not belong to this purchase order", received.LineID)
+			}
+
+			if received.Quantity < 1 || line.QuantityReceived+received.Quantity > line.QuantityOrdered {
+				return fmt.Errorf("invalid received quantity for line %v", received.LineID)
+			}
+
+			if received.UnitCost < 0 {
+				return fmt.Errorf("invalid unit cost for line %v", received.LineID)
+			}
+
+			if received.UnitCost > 0 {
+				line.UnitCost = received.UnitCost
+			}
+			line.QuantityReceived = line.QuantityReceived + received.Quantity
+
+			result := tx.Model(&PurchaseOrderLine{}).Where("id = ?", line.ID).Updates(map[string]interface{}{
+				"quantity_received": line.QuantityReceived,
+				"unit_cost":         line.UnitCost,
+			})
+			if result.Error != nil {
+				return result.Error
+			}
+
+			receipt := PurchaseReceipt{
+				ID:              uuid.New(),
+				PurchaseOrderID: id,
+				LineID:          line.ID,
+				ProductID:       line.ProductID,
+				VariantID:       line.VariantID,
+				LocationID:      locationID,
+				Quantity:        received.Quantity,
+				UnitCost:        line.UnitCost,
+				UserID:          userId,
+			}
+
+			if result := tx.Create(&receipt); result.Error != nil {
+				return result.Error
+			}
+
+			unitCost := line.UnitCost
+			changes = append(changes, stockChange{
+				ProductID:   line.ProductID,
+				VariantID:   line.VariantID,
+				LocationID:  locationID,
+				Delta:       received.Quantity,
+				Reason:      MovementRestock,
+				ReferenceID: id,
+				UserID:      userId,
+				UnitCost:    &unitCost,
+			})
+		}
+
+		//Stock is only touched once every line checks out, in lock order
+		sortStockChanges(changes)
+
+		for _, change := range changes {
+			if _, err := applyStockChange(tx, change); err != nil {
+				return err
+			}
+		}
+
+		status := POReceived
+		for _, line := range order.Lines {
+			if line.QuantityReceived < line.QuantityOrdered {
+				status = POPartiallyReceived
+				break
+			}
+		}
+
+		return tx.Model(&PurchaseOrder{}).Where("id = ?", id).Update("status", status).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return p.GetPurchaseOrder(id)
+}
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/loyalsfc/investrite/data"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPerPage = 20
+	maxPerPage     = 100
+)
+
+type ListMeta struct {
+	Page       int    `json:"page"`
+	PerPage    int    `json:"per_page"`
+	Total      int64  `json:"total"`
+	TotalPages int    `json:"total_pages"`
+	Sort       string `json:"sort"`
+	Order      string `json:"order"`
+}
+
+// listSort maps the sort keys a list endpoint accepts to their columns, along
+// with the key and direction used when the client does not ask for one.
+type listSort struct {
+	Columns      map[string]string
+	DefaultKey   string
+	DefaultOrder string
+}
+
+// resolveSort fills in the default sort and direction and turns them into an
+// ORDER BY clause. Unknown sort keys are rejected rather than interpolated
+// into SQL.
+func resolveSort(params data.ListParams, sorting listSort) (string, string, string, error) {
+	key := params.Sort
+	order := strings.ToLower(params.Order)
+
+	if key == "" {
+		key = sorting.DefaultKey
+		if order == "" {
+			order = sorting.DefaultOrder
+		}
+	}
+
+	if order == "" {
+		order = "asc"
+	}
+
+	column, ok := sorting.Columns[key]
+	if !ok {
+		return "", "", "", fmt.Errorf("cannot sort by %v", key)
+	}
+
+	if order != "asc" && order != "desc" {
+		return "", "", "", fmt.Errorf("order must be asc or desc")
+	}
+
+	return key, order, column + " " + order, nil
+}
+
+// paginate counts the rows matched by query, then loads one page of them into
+// dest.
+func paginate(query *gorm.DB, params data.ListParams, sorting listSort, dest interface{}) (*ListMeta, error) {
+	key, order, clause, err := resolveSort(params, sorting)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := ListMeta{
+		Page:    params.Page,
+		PerPage: params.PerPage,
+		Sort:    key,
+		Order:   order,
+	}
+
+	if meta.Page < 1 {
+		meta.Page = 1
+	}
+
+	if meta.PerPage < 1 {
+		meta.PerPage = defaultPerPage
+	}
+
+	if meta.PerPage > maxPerPage {
+		meta.PerPage = maxPerPage
+	}
+
+	//A new session lets the same conditions drive both the count and the page
+	query = query.Session(&gorm.Session{})
+
+	if result := query.Count(&meta.Total); result.Error != nil {
+		return nil, result.Error
+	}
+
+	meta.TotalPages = int((meta.Total + int64(meta.PerPage) - 1) / int64(meta.PerPage))
+
+	result := query.
+		Order(clause).
+		Offset((meta.Page - 1) * meta.PerPage).
+		Limit(meta.PerPage).
+		Find(dest)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &meta, nil
+}
@@ -0,0 +1,248 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/loyalsfc/investrite/data"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Refund struct {
+	ID            uuid.UUID          `json:"id" gorm:"column:id;unique;not null"`
+	OrderID       uuid.UUID          `json:"order_id" gorm:"column:order_id;index;not null"`
+	Items         []RefundItem       `json:"items" gorm:"foreignKey:RefundID;references:ID"`
+	PaymentMethod data.PaymentMethod `json:"payment_method" gorm:"column:payment_method;embedded;not null"`
+	Amount        int                `json:"amount" gorm:"column:amount;not null"`
+	Restocked     bool               `json:"restocked" gorm:"column:restocked;not null"`
+	Reason        string             `json:"reason" gorm:"column:reason"`
+	UserID        uuid.UUID          `json:"user_id" gorm:"column:user_id;not null"`
+	gorm.Model
+}
+
+type RefundItem struct {
+	ID          uuid.UUID `json:"id" gorm:"column:id;unique;not null"`
+	RefundID    uuid.UUID `json:"refund_id" gorm:"column:refund_id;index;not null"`
+	OrderItemID uuid.UUID `json:"order_item_id" gorm:"column:order_item_id;not null"`
+	ProductID   uuid.UUID `json:"product_id" gorm:"column:product_id;not null"`
+	VariantID   uuid.UUID `json:"variant_id" gorm:"column:variant_id"`
+	Quantity    int       `json:"quantity" gorm:"column:quantity;not null"`
+	Amount      int       `json:"amount" gorm:"column:amount;not null"`
+	gorm.Model
+}
+
+func (o OrderService) RefundOrder(id uuid.UUID, param data.RefundParams, userId uuid.UUID) (*Refund, error) {
+	refund := Refund{
+		ID:        uuid.New(),
+		OrderID:   id,
+		Restocked: param.Restock,
+		Reason:    param.Reason,
+		UserID:    userId,
+	}
+
+	err := stockTransaction(o.DB, func(tx *gorm.DB) error {
+		var order Order
+
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("order_id = ?", id).First(&order)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if order.Status != completed && order.Status != partiallyRefunded {
+			return fmt.Errorf("order with status %v cannot be refunded", order.Status)
+		}
+
+		if result := tx.Where("order_id = ?", id).Find(&order.Items); result.Error != nil {
+			return result.Error
+		}
+
+		quantities, err := refundQuantities(order.Items, param.Items)
+		if err != nil {
+			return err
+		}
+
+		var restocks []stockChange
+
+		for i := range order.Items {
+			item := &order.Items[i]
+			quantity := quantities[item.ID]
+
+			if quantity == 0 {
+				continue
+			}
+
+			//Returned units go back with their share of what the line cost,
+			//counted cumulatively so a fully refunded line returns all of it
+			returnedCost := item.CostTotal*(item.RefundedQuantity+quantity)/item.Quantity -
+				item.CostTotal*item.RefundedQuantity/item.Quantity
+
+			amount := item.UnitPrice * quantity
+			refund.Amount = refund.Amount + amount
+			refund.Items = append(refund.Items, RefundItem{
+				ID:          uuid.New(),
+				RefundID:    refund.ID,
+				OrderItemID: item.ID,
+				ProductID:   item.ProductID,
+				VariantID:   item.VariantID,
+				Quantity:    quantity,
+				Amount:      amount,
+			})
+
+			item.RefundedQuantity = item.RefundedQuantity + quantity
+			if result := tx.Model(&OrderItem{}).Where("id = ?", item.ID).Update("refunded_quantity", item.RefundedQuantity); result.Error != nil {
+				return result.Error
+			}
+
+			if param.Restock {
+				restocks = append(restocks, stockChange{
+					ProductID:   item.ProductID,
+					VariantID:   item.VariantID,
+					LocationID:  order.LocationID,
+					Delta:       quantity,
+					Reason:      MovementReturn,
+					ReferenceID: refund.ID,
+					UserID:      userId,
+					TotalCost:   &returnedCost,
+				})
+			}
+		}
+
+		sortStockChanges(restocks)
+
+		for _, change := range restocks {
+			if _, err := applyStockChange(tx, change); err != nil {
+				return err
+			}
+		}
+
+		var previous data.PaymentMethod
+		result = tx.Model(&Refund{}).
+			Select("COALESCE(SUM(cash), 0) AS cash, COALESCE(SUM(transfer), 0) AS transfer, COALESCE(SUM(pos), 0) AS pos").
+			Where("order_id = ?", id).
+			Scan(&previous)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		available := data.PaymentMethod{
+			Cash:     order.PaymentMethod.Cash - previous.Cash,
+			Transfer: order.PaymentMethod.Transfer - previous.Transfer,
+			Pos:      order.PaymentMethod.Pos - previous.Pos,
+		}
+
+		split, err := splitRefund(refund.Amount, param.PaymentMethod, available)
+		if err != nil {
+			return err
+		}
+		refund.PaymentMethod = split
+
+		status := refunded
+		for _, item := range order.Items {
+			if item.RefundedQuantity < item.Quantity {
+				status = partiallyRefunded
+				break
+			}
+		}
+
+		if result := tx.Model(&Order{}).Where("order_id = ?", id).Update("status", status); result.Error != nil {
+			return result.Error
+		}
+
+		if result := tx.Create(&refund); result.Error != nil {
+			return result.Error
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &refund, nil
+}
+
+// refundQuantities resolves the requested lines against the order. An empty
+// request refunds everything that has not been refunded yet.
+func refundQuantities(items []OrderItem, lines []data.RefundItemParams) (map[uuid.UUID]int, error) {
+	quantities := map[uuid.UUID]int{}
+
+	if len(lines) == 0 {
+		for _, item := range items {
+			if remaining := item.Quantity - item.RefundedQuantity; remaining > 0 {
+				quantities[item.ID] = remaining
+			}
+		}
+	}
+
+	for _, line := range lines {
+		if line.Quantity < 1 {
+			return nil, fmt.Errorf("invalid refund quantity for item %v", line.OrderItemID)
+		}
+
+		quantities[line.OrderItemID] = quantities[line.OrderItemID] + line.Quantity
+	}
+
+	if len(quantities) == 0 {
+		return nil, errors.New("nothing left to refund on this order")
+	}
+
+	for id, quantity := range quantities {
+		found := false
+
+		for _, item := range items {
+			if item.ID != id {
+				continue
+			}
+
+			found = true
+			if quantity > item.Quantity-item.RefundedQuantity {
+				return nil, fmt.Errorf("cannot refund %v of %v, only %v refundable", quantity, item.ProductName, item.Quantity-item.RefundedQuantity)
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("order item %v does not belong to this order", id)
+		}
+	}
+
+	return quantities, nil
+}
+
+// splitRefund validates a requested split across payment buckets, or when none
+// is given pays back cash first, then transfer, then pos.
+func splitRefund(amount int, requested data.PaymentMethod, available data.PaymentMethod) (data.PaymentMethod, error) {
+	if requested.Cash+requested.Transfer+requested.Pos == 0 {
+		split := data.PaymentMethod{}
+		remaining := amount
+
+		split.Cash = min(remaining, available.Cash)
+		remaining = remaining - split.Cash
+		split.Transfer = min(remaining, available.Transfer)
+		remaining = remaining - split.Transfer
+		split.Pos = min(remaining, available.Pos)
+		remaining = remaining - split.Pos
+
+		if remaining > 0 {
+			return split, errors.New("refund exceeds the amount left on the order")
+		}
+
+		return split, nil
+	}
+
+	if requested.Cash < 0 || requested.Transfer < 0 || requested.Pos < 0 {
+		return requested, errors.New("refund amounts cannot be negative")
+	}
+
+	if requested.Cash+requested.Transfer+requested.Pos != amount {
+		return requested, fmt.Errorf("refund split must add up to %v", amount)
+	}
+
+	if requested.Cash > available.Cash || requested.Transfer > available.Transfer || requested.Pos > available.Pos {
+		return requested, errors.New("refund split exceeds what was paid with that method")
+	}
+
+	return requested, nil
+}
@@ -0,0 +1,231 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/loyalsfc/investrite/data"
+	"gorm.io/gorm"
+)
+
+type ReportService struct {
+	DB *gorm.DB
+}
+
+type SalesBucket struct {
+	Start         string `json:"start,omitempty"`
+	Orders        int    `json:"orders"`
+	Gross         int    `json:"gross"`
+	Cash          int    `json:"cash"`
+	Transfer      int    `json:"transfer"`
+	Pos           int    `json:"pos"`
+	Refunded      int    `json:"refunded"`
+	Net           int    `json:"net"`
+	AverageBasket int    `json:"average_basket"`
+}
+
+type TopProduct struct {
+	ProductID   string `json:"product_id"`
+	ProductName string `json:"product_name"`
+	Quantity    int    `json:"quantity"`
+	Revenue     int    `json:"revenue"`
+}
+
+type TopCategory struct {
+	CategoryID   string `json:"category_id"`
+	CategoryName string `json:"category_name"`
+	Quantity     int    `json:"quantity"`
+	Revenue      int    `json:"revenue"`
+}
+
+type SalesReport struct {
+	Period        string        `json:"period"`
+	Timezone      string        `json:"timezone"`
+	From          string        `json:"from"`
+	To            string        `json:"to"`
+	Totals        SalesBucket   `json:"totals"`
+	Buckets       []SalesBucket `json:"buckets"`
+	TopProducts   []TopProduct  `json:"top_products"`
+	TopCategories []TopCategory `json:"top_categories"`
+}
+
+// reportRange turns the inclusive local dates of a report into the UTC
+// instants that bound it, defaulting to the last 30 days.
+func reportRange(from time.Time, to time.Time, location *time.Location) (time.Time, time.Time, error) {
+	today := time.Now().In(location)
+
+	if to.IsZero() {
+		to = today
+	}
+
+	if from.IsZero() {
+		from = to.AddDate(0, 0, -29)
+	}
+
+	start := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, location)
+	end := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, location).AddDate(0, 0, 1)
+
+	if !start.Before(end) {
+		return time.Time{}, time.Time{}, errors.New("from must not be after to")
+	}
+
+	if end.Sub(start) > 366*24*time.Hour*3 {
+		return time.Time{}, time.Time{}, errors.New("report range cannot exceed three years")
+	}
+
+	return start, end, nil
+}
+
+// SalesSummary aggregates completed and refunded orders into day, week or
+// month buckets in the requested timezone. Refunds are counted in the bucket
+// they were issued in, not the one of the original sale.
+func (r ReportService) SalesSummary(param data.SalesReportParams) (*SalesReport, error) {
+	period := param.Period
+	if period == "" {
+		period = "day"
+	}
+
+	if period != "day" && period != "week" && period != "month" {
+		return nil, errors.New("period must be day, week or month")
+	}
+
+	timezone := param.Timezone
+	if timezone == "" {
+		timezone = "UTC"
+	}
+
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %v", timezone)
+	}
+
+	start, end, err := reportRange(param.From, param.To, location)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := param.Limit
+	if limit < 1 || limit > 50 {
+		limit = 10
+	}
+
+	args := map[string]interface{}{
+		"period":   period,
+		"tz":       timezone,
+		"start":    start,
+		"end":      end,
+		"statuses": []Status{completed, refunded, partiallyRefunded},
+		"limit":    limit,
+	}
+
+	report := SalesReport{
+		Period:   period,
+		Timezone: timezone,
+		From:     start.Format("2006-01-02"),
+		To:       end.AddDate(0, 0, -1).Format("2006-01-02"),
+	}
+
+	var sales []SalesBucket
+	result := r.DB.Raw(`SELECT to_char(date_trunc(@period, created_at AT TIME ZONE @tz), 'YYYY-MM-DD') AS start,
+			count(*) AS orders,
+			coalesce(sum(total_price), 0) AS gross,
+			coalesce(sum(cash), 0) AS cash,
+			coalesce(sum(transfer), 0) AS transfer,
+			coalesce(sum(pos), 0) AS pos
+		FROM orders
+		WHERE deleted_at IS NULL AND status IN @statuses AND created_at >= @start AND created_at < @end
+		GROUP BY 1
+		ORDER BY 1`, args).Scan(&sales)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	var refunds []SalesBucket
+	result = r.DB.Raw(`SELECT to_char(date_trunc(@period, created_at AT TIME ZONE @tz), 'YYYY-MM-DD') AS start,
+			coalesce(sum(amount), 0) AS refunded
+		FROM refunds
+		WHERE deleted_at IS NULL AND created_at >= @start AND created_at < @end
+		GROUP BY 1`, args).Scan(&refunds)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	refundedIn := map[string]int{}
+	for _, refund := range refunds {
+		refundedIn[refund.Start] = refund.Refunded
+	}
+
+	seen := map[string]bool{}
+	for _, bucket := range sales {
+		seen[bucket.Start] = true
+		bucket.Refunded = refundedIn[bucket.Start]
+		report.Buckets = append(report.Buckets, bucket)
+	}
+
+	//Keep buckets that only saw refunds so the totals add up
+	for _, refund := range refunds {
+		if !seen[refund.Start] {
+			report.Buckets = append(report.Buckets, refund)
+		}
+	}
+
+	for i := range report.Buckets {
+		bucket := &report.Buckets[i]
+		bucket.Net = bucket.Gross - bucket.Refunded
+		if bucket.Orders > 0 {
+			bucket.AverageBasket = bucket.Gross / bucket.Orders
+		}
+
+		report.Totals.Orders = report.Totals.Orders + bucket.Orders
+		report.Totals.Gross = report.Totals.Gross + bucket.Gross
+		report.Totals.Cash = report.Totals.Cash + bucket.Cash
+		report.Totals.Transfer = report.Totals.Transfer + bucket.Transfer
+		report.Totals.Pos = report.Totals.Pos + bucket.Pos
+		report.Totals.Refunded = report.Totals.Refunded + bucket.Refunded
+	}
+
+	report.Totals.Net = report.Totals.Gross - report.Totals.Refunded
+	if report.Totals.Orders > 0 {
+		report.Totals.AverageBasket = report.Totals.Gross / report.Totals.Orders
+	}
+
+	sort.Slice(report.Buckets, func(i, j int) bool {
+		return report.Buckets[i].Start < report.Buckets[j].Start
+	})
+
+	result = r.DB.Raw(`SELECT order_items.product_id::text AS product_id,
+			max(order_items.product_name) AS product_name,
+			sum(order_items.quantity - order_items.refunded_quantity) AS quantity,
+			sum((order_items.quantity - order_items.refunded_quantity) * order_items.unit_price) AS revenue
+		FROM order_items
+		JOIN orders ON orders.order_id = order_items.order_id
+		WHERE orders.deleted_at IS NULL AND order_items.deleted_at IS NULL AND orders.status IN @statuses
+			AND orders.created_at >= @start AND orders.created_at < @end
+		GROUP BY order_items.product_id
+		ORDER BY revenue DESC, quantity DESC
+		LIMIT @limit`, args).Scan(&report.TopProducts)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	result = r.DB.Raw(`SELECT categories.id::text AS category_id,
+			max(categories.name) AS category_name,
+			sum(order_items.quantity - order_items.refunded_quantity) AS quantity,
+			sum((order_items.quantity - order_items.refunded_quantity) * order_items.unit_price) AS revenue
+		FROM order_items
+		JOIN orders ON orders.order_id = order_items.order_id
+		JOIN products ON products.id = order_items.product_id
+		JOIN categories ON categories.id = products.category_id
+		WHERE orders.deleted_at IS NULL AND order_items.deleted_at IS NULL AND orders.status IN @statuses
+			AND orders.created_at >= @start AND orders.created_at < @end
+		GROUP BY categories.id
+		ORDER BY revenue DESC, quantity DESC
+		LIMIT @limit`, args).Scan(&report.TopCategories)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &report, nil
+}
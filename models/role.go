@@ -0,0 +1,250 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/google/uuid"
+	"github.com/loyalsfc/investrite/data"
+	"github.com/loyalsfc/investrite/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Role is a named set of permissions. Users reference roles by name, so the
+// four built-in roles keep working for accounts created before roles were
+// stored.
+type Role struct {
+	ID          uuid.UUID  `json:"id" gorm:"column:id;unique;not null"`
+	Name        string     `json:"name" gorm:"column:name;unique;not null"`
+	Description string     `json:"description" gorm:"column:description"`
+	Permissions StringList `json:"permissions" gorm:"column:permissions;type:jsonb;not null"`
+	BuiltIn     bool       `json:"built_in" gorm:"column:built_in;default:false;not null"`
+	gorm.Model
+}
+
+type RoleService struct {
+	DB *gorm.DB
+}
+
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{1,31}$`)
+
+var builtInRoles = []utils.UserRole{utils.AdminRole, utils.SupervisorRole, utils.OperatorRole, utils.ViewerRole}
+
+func validateRole(param data.RoleParams) (StringList, error) {
+	if !roleNamePattern.MatchString(param.Name) {
+		return nil, errors.New("role names are 2-32 lowercase letters, digits or underscores")
+	}
+
+	seen := map[utils.Permission]bool{}
+	var permissions StringList
+
+	for _, permission := range param.Permissions {
+		if !utils.IsValidPermission(permission) {
+			return nil, fmt.Errorf("unknown permission %v", permission)
+		}
+
+		if !seen[permission] {
+			seen[permission] = true
+			permissions = append(permissions, string(permission))
+		}
+	}
+
+	if permissions == nil {
+		permissions = StringList{}
+	}
+
+	return permissions, nil
+}
+
+func (r RoleService) GetRoles() ([]Role, error) {
+	var roles []Role
+
+	if result := r.DB.Order("built_in desc, name asc").Find(&roles); result.Error != nil {
+		return nil, result.Error
+	}
+
+	return roles, nil
+}
+
+func (r RoleService) GetRole(id uuid.UUID) (*Role, error) {
+	var role Role
+
+	if result := r.DB.Where("id = ?", id).First(&role); result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &role, nil
+}
+
+func (r RoleService) RoleExists(name string) bool {
+	return r.DB.Where("name = ?", name).First(&Role{}).Error == nil
+}
+
+func (r RoleService) CreateRole(param data.RoleParams) (*Role, error) {
+	permissions, err := validateRole(param)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.RoleExists(param.Name) {
+		return nil, errors.New("a role with this name already exists")
+	}
+
+	role := Role{
+		ID:          uuid.New(),
+		Name:        param.Name,
+		Description: param.Description,
+		Permissions: permissions,
+	}
+
+	if result := r.DB.Create(&role); result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &role, nil
+}
+
+// UpdateRole renames a custom role along with every user and invitation
+// holding it. The admin role always has every permission and cannot be
+// edited, and built-in roles keep their names.
+func (r RoleService) UpdateRole(id uuid.UUID, param data.RoleParams) error {
+	permissions, err := validateRole(param)
+	if err != nil {
+		return err
+	}
+
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var role Role
+
+		if result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&role); result.Error != nil {
+			return result.Error
+		}
+
+		if role.Name == string(utils.AdminRole) {
+			return errors.New("the admin role cannot be edited")
+		}
+
+		if role.Name != param.Name {
+			if role.BuiltIn {
+				return errors.New("built-in roles cannot be renamed")
+			}
+
+			if tx.Where("name = ?", param.Name).First(&Role{}).Error == nil {
+				return errors.New("a role with this name already exists")
+			}
+
+			if result := tx.Model(&User{}).Where("role = ?", role.Name).Update("role", param.Name); result.Error != nil {
+				return result.Error
+			}
+
+			if result := tx.Model(&Invitation{}).Where("role = ?", role.Name).Update("role", param.Name); result.Error != nil {
+				return result.Error
+			}
+		}
+
+		return tx.Model(&Role{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"name":        param.Name,
+			"description": param.Description,
+			"permissions": permissions,
+		}).Error
+	})
+}
+
+func (r RoleService) DeleteRole(id uuid.UUID) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var role Role
+
+		if result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&role); result.Error != nil {
+			return result.Error
+		}
+
+		if role.BuiltIn {
+			return errors.New("built-in roles cannot be deleted")
+		}
+
+		var users int64
+		if result := tx.Model(&User{}).Where("role = ?", role.Name).Count(&users); result.Error != nil {
+			return result.Error
+		}
+
+		var invitations int64
+		if result := tx.Model(&Invitation{}).Where("role = ? AND accepted_at IS NULL", role.Name).Count(&invitations); result.Error != nil {
+			return result.Error
+		}
+
+		if users > 0 || invitations > 0 {
+			return fmt.Errorf("role is held by %v users and %v pending invitations", users, invitations)
+		}
+
+		return tx.Where("id = ?", id).Delete(&Role{}).Error
+	})
+}
+
+func (r Role) PermissionList() []utils.Permission {
+	permissions := make([]utils.Permission, 0, len(r.Permissions))
+	for _, permission := range r.Permissions {
+		permissions = append(permissions, utils.Permission(permission))
+	}
+
+	return permissions
+}
+
+// RolePermissions resolves what holders of a role may do. Admins always get
+// the full set, including permissions added after their role was stored, and
+// roles that no longer exist grant nothing.
+func (r RoleService) RolePermissions(name string) ([]utils.Permission, error) {
+	if name == string(utils.AdminRole) {
+		return utils.AllPermissions(), nil
+	}
+
+	var role Role
+	result := r.DB.Where("name = ?", name).First(&role)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return role.PermissionList(), nil
+}
+
+// GetPermissions resolves what a user may do through their role.
+func (r RoleService) GetPermissions(userID uuid.UUID) ([]utils.Permission, error) {
+	user, err := UserService{DB: r.DB}.GetUserById(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.RolePermissions(user.Role)
+}
+
+// MigrateRoles stores the built-in roles with the permissions matching the
+// role levels they replace.
+func MigrateRoles(db *gorm.DB) error {
+	for _, name := range builtInRoles {
+		if db.Where("name = ?", name).First(&Role{}).Error == nil {
+			continue
+		}
+
+		var permissions StringList
+		for _, permission := range utils.DefaultPermissions(name) {
+			permissions = append(permissions, string(permission))
+		}
+
+		role := Role{
+			ID:          uuid.New(),
+			Name:        string(name),
+			Permissions: permissions,
+			BuiltIn:     true,
+		}
+
+		if result := db.Create(&role); result.Error != nil {
+			return result.Error
+		}
+	}
+
+	return nil
+}
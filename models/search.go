@@ -0,0 +1,98 @@
+package models
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/loyalsfc/investrite/data"
+	"gorm.io/gorm"
+)
+
+// productDocument must stay identical to the expression indexed in
+// MigrateSearchIndexes, otherwise PostgreSQL will not use the index.
+const productDocument = `to_tsvector('english', coalesce(products.name, '') || ' ' || coalesce(products.description, '') || ' ' || coalesce(products.sku, ''))`
+
+type ProductSearchResult struct {
+	Product
+	CategoryName string  `json:"category_name"`
+	Rank         float64 `json:"rank"`
+}
+
+// SearchProducts matches the query against the full-text document of each
+// product and, for typo tolerance, against trigrams of the name, SKU and
+// category name. Full-text hits weigh more than fuzzy ones.
+func (p ProductService) SearchProducts(q string, params data.ListParams) ([]ProductSearchResult, *ListMeta, error) {
+	q = strings.TrimSpace(q)
+	if len(q) < 2 {
+		return nil, nil, errors.New("search query must be at least 2 characters")
+	}
+
+	meta := ListMeta{Page: params.Page, PerPage: params.PerPage, Sort: "relevance", Order: "desc"}
+	if meta.Page < 1 {
+		meta.Page = 1
+	}
+	if meta.PerPage < 1 {
+		meta.PerPage = defaultPerPage
+	}
+	if meta.PerPage > maxPerPage {
+		meta.PerPage = maxPerPage
+	}
+
+	like := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(q) + "%"
+
+	from := `FROM products LEFT JOIN categories ON categories.id = products.category_id AND categories.deleted_at IS NULL
+		WHERE products.deleted_at IS NULL AND (
+			` + productDocument + ` @@ websearch_to_tsquery('english', @q)
+			OR @q <% products.name
+			OR @q <% coalesce(products.sku, '')
+			OR @q <% coalesce(categories.name, '')
+			OR products.name ILIKE @like
+			OR products.sku ILIKE @like
+		)`
+	args := map[string]interface{}{"q": q, "like": like}
+
+	if result := p.DB.Raw("SELECT count(*) "+from, args).Scan(&meta.Total); result.Error != nil {
+		return nil, nil, result.Error
+	}
+	meta.TotalPages = int((meta.Total + int64(meta.PerPage) - 1) / int64(meta.PerPage))
+
+	args["limit"] = meta.PerPage
+	args["offset"] = (meta.Page - 1) * meta.PerPage
+
+	var results []ProductSearchResult
+	result := p.DB.Raw(`SELECT products.*, categories.name AS category_name,
+			2 * ts_rank(`+productDocument+`, websearch_to_tsquery('english', @q))
+			+ GREATEST(
+				word_similarity(@q, products.name),
+				word_similarity(@q, coalesce(products.sku, '')),
+				word_similarity(@q, coalesce(categories.name, '')) / 2
+			) AS rank
+		`+from+`
+		ORDER BY rank DESC, products.name ASC
+		LIMIT @limit OFFSET @offset`, args).Scan(&results)
+	if result.Error != nil {
+		return nil, nil, result.Error
+	}
+
+	return results, &meta, nil
+}
+
+// MigrateSearchIndexes enables pg_trgm and creates the indexes product search
+// relies on. Every statement is idempotent.
+func MigrateSearchIndexes(db *gorm.DB) error {
+	statements := []string{
+		`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+		`CREATE INDEX IF NOT EXISTS idx_products_search_document ON products USING GIN (` + productDocument + `)`,
+		`CREATE INDEX IF NOT EXISTS idx_products_name_trgm ON products USING GIN (name gin_trgm_ops)`,
+		`CREATE INDEX IF NOT EXISTS idx_products_sku_trgm ON products USING GIN ((coalesce(sku, '')) gin_trgm_ops)`,
+		`CREATE INDEX IF NOT EXISTS idx_categories_name_trgm ON categories USING GIN ((coalesce(name, '')) gin_trgm_ops)`,
+	}
+
+	for _, statement := range statements {
+		if result := db.Exec(statement); result.Error != nil {
+			return result.Error
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,371 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/loyalsfc/investrite/data"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type MovementReason string
+
+const (
+	MovementSale        MovementReason = "sale"
+	MovementRestock     MovementReason = "restock"
+	MovementAdjustment  MovementReason = "adjustment"
+	MovementReturn      MovementReason = "return"
+	MovementTransferIn  MovementReason = "transfer_in"
+	MovementTransferOut MovementReason = "transfer_out"
+)
+
+type StockMovement struct {
+	ID            uuid.UUID      `json:"id" gorm:"column:id;unique;not null"`
+	ProductID     uuid.UUID      `json:"product_id" gorm:"column:product_id;index;not null"`
+	VariantID     uuid.UUID      `json:"variant_id" gorm:"column:variant_id"`
+	LocationID    uuid.UUID      `json:"location_id" gorm:"column:location_id;index"`
+	Delta         int            `json:"delta" gorm:"column:delta;not null"`
+	QuantityAfter int            `json:"quantity_after" gorm:"column:quantity_after;not null"`
+	Reason        MovementReason `json:"reason" gorm:"column:reason;not null"`
+	ReferenceID   uuid.UUID      `json:"reference_id" gorm:"column:reference_id"`
+	UserID        uuid.UUID      `json:"user_id" gorm:"column:user_id"`
+	Note          string         `json:"note" gorm:"column:note"`
+	Cost          int            `json:"cost" gorm:"column:cost;default:0;not null"`
+	gorm.Model
+}
+
+type StockLedger struct {
+	ProductID      uuid.UUID       `json:"product_id"`
+	Quantity       int             `json:"quantity"`
+	LedgerQuantity int             `json:"ledger_quantity"`
+	Movements      []StockMovement `json:"movements"`
+}
+
+type stockChange struct {
+	ProductID   uuid.UUID
+	VariantID   uuid.UUID
+	LocationID  uuid.UUID
+	Delta       int
+	Reason      MovementReason
+	ReferenceID uuid.UUID
+	UserID      uuid.UUID
+	Note        string
+	UnitCost    *int
+	TotalCost   *int
+}
+
+type stockKey struct {
+	ProductID uuid.UUID
+	VariantID uuid.UUID
+}
+
+func (k stockKey) String() string {
+	return k.ProductID.String() + "/" + k.VariantID.String()
+}
+
+// sortStockChanges puts changes in the order every stock writer locks rows
+// in, by product and then variant, so two transactions never wait on each
+// other's locks.
+func sortStockChanges(changes []stockChange) {
+	sort.SliceStable(changes, func(i, j int) bool {
+		a := stockKey{ProductID: changes[i].ProductID, VariantID: changes[i].VariantID}
+		b := stockKey{ProductID: changes[j].ProductID, VariantID: changes[j].VariantID}
+		return a.String() < b.String()
+	})
+}
+
+// stockLevel is the state after a change: Product.Quantity (and
+// Variant.Quantity) are totals across locations and Available is what remains
+// at the location that was changed.
+type stockLevel struct {
+	Product    *Product
+	Variant    *ProductVariant
+	LocationID uuid.UUID
+	Available  int
+	Cost       int
+}
+
+type AdjustmentResult struct {
+	ProductID  uuid.UUID `json:"product_id"`
+	VariantID  uuid.UUID `json:"variant_id"`
+	LocationID uuid.UUID `json:"location_id"`
+	Before     int       `json:"before"`
+	After      int       `json:"after"`
+}
+
+// IsValidAdjustmentReason lists the reasons a manual adjustment may give.
+// Sales, returns and transfers are only recorded by the flows that own them,
+// which always set a reference.
+func IsValidAdjustmentReason(reason MovementReason) bool {
+	switch reason {
+	case MovementRestock, MovementAdjustment:
+		return true
+	default:
+		return false
+	}
+}
+
+type changedProductsKey struct{}
+
+// stockTransaction runs fn in a transaction and, once it has committed, queues
+// a low-stock check for every product applyStockChange touched. The touched
+// ids travel on the statement context, which every session derived from tx
+// keeps.
+func stockTransaction(db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	changed := &[]uuid.UUID{}
+
+	ctx := context.WithValue(db.Statement.Context, changedProductsKey{}, changed)
+
+	err := db.WithContext(ctx).Transaction(fn)
+
+	if err == nil {
+		queueStockCheck(*changed...)
+	}
+
+	return err
+}
+
+// applyStockChange locks the product row, moves its quantity (or that of one
+// of its variants) at the given location, the default location when none is
+// set, and writes the matching ledger entry. It must be called inside a
+// transaction.
+func applyStockChange(tx *gorm.DB, change stockChange) (*stockLevel, error) {
+	var product Product
+
+	result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", change.ProductID).First(&product)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if ids, ok := tx.Statement.Context.Value(changedProductsKey{}).(*[]uuid.UUID); ok {
+		*ids = append(*ids, product.ID)
+	}
+
+	locationID, err := resolveLocationID(tx, change.LocationID)
+	if err != nil {
+		return nil, err
+	}
+
+	var variant *ProductVariant
+	if change.VariantID != uuid.Nil {
+		variant = &ProductVariant{}
+
+		if result := tx.Where("id = ? AND product_id = ?", change.VariantID, product.ID).First(variant); result.Error != nil {
+			return nil, fmt.Errorf("variant %v does not belong to %v", change.VariantID, product.Name)
+		}
+	} else if change.Delta != 0 {
+		hasVariants, err := productHasVariants(tx, product.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		if hasVariants {
+			return nil, fmt.Errorf("%v has variants, specify a variant_id", product.Name)
+		}
+	}
+
+	stock, err := lockProductStock(tx, product.ID, change.VariantID, locationID)
+	if err != nil {
+		return nil, err
+	}
+
+	level := &stockLevel{Product: &product, Variant: variant, LocationID: locationID, Available: stock.Quantity}
+
+	if change.Delta == 0 {
+		return level, nil
+	}
+
+	if stock.Quantity+change.Delta < 0 {
+		return nil, fmt.Errorf("insufficient stock for %v, %v available at this location", product.Name, stock.Quantity)
+	}
+
+	stock.Quantity = stock.Quantity + change.Delta
+	product.Quantity = product.Quantity + change.Delta
+
+	if result := tx.Model(&ProductStock{}).Where("id = ?", stock.ID).Update("quantity", stock.Quantity); result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result := tx.Model(&Product{}).Where("id = ?", product.ID).Update("quantity", product.Quantity); result.Error != nil {
+		return nil, result.Error
+	}
+
+	if variant != nil {
+		variant.Quantity = variant.Quantity + change.Delta
+
+		if result := tx.Model(&ProductVariant{}).Where("id = ?", variant.ID).Update("quantity", variant.Quantity); result.Error != nil {
+			return nil, result.Error
+		}
+	}
+
+	movementID := uuid.New()
+
+	cost, err := recordCost(tx, &product, change, movementID)
+	if err != nil {
+		return nil, err
+	}
+
+	movement := StockMovement{
+		ID:            movementID,
+		ProductID:     product.ID,
+		VariantID:     change.VariantID,
+		LocationID:    locationID,
+		Delta:         change.Delta,
+		QuantityAfter: product.Quantity,
+		Reason:        change.Reason,
+		ReferenceID:   change.ReferenceID,
+		UserID:        change.UserID,
+		Note:          change.Note,
+		Cost:          cost,
+	}
+
+	if result := tx.Create(&movement); result.Error != nil {
+		return nil, result.Error
+	}
+
+	level.Available = stock.Quantity
+	level.Cost = cost
+	return level, nil
+}
+
+func (p ProductService) AdjustQuantities(param data.AdjustQuantityParams, userId uuid.UUID) ([]AdjustmentResult, error) {
+	if len(param.Adjustments) == 0 {
+		return nil, errors.New("no adjustments provided")
+	}
+
+	for _, adjustment := range param.Adjustments {
+		if (adjustment.Delta == nil) == (adjustment.AbsoluteCount == nil) {
+			return nil, fmt.Errorf("provide either delta or absolute_count for product %v", adjustment.ProductID)
+		}
+
+		if adjustment.AbsoluteCount != nil && *adjustment.AbsoluteCount < 0 {
+			return nil, fmt.Errorf("absolute_count cannot be negative for product %v", adjustment.ProductID)
+		}
+
+		if !IsValidAdjustmentReason(MovementReason(adjustment.Reason)) {
+			return nil, fmt.Errorf("reason for product %v must be %v or %v", adjustment.ProductID, MovementRestock, MovementAdjustment)
+		}
+	}
+
+	//Lock rows in the same order as CreateOrder so concurrent writers cannot deadlock
+	positions := make([]int, len(param.Adjustments))
+	for i := range positions {
+		positions[i] = i
+	}
+	sort.SliceStable(positions, func(i, j int) bool {
+		a, b := param.Adjustments[positions[i]], param.Adjustments[positions[j]]
+		return stockKey{ProductID: a.ProductID, VariantID: a.VariantID}.String() < stockKey{ProductID: b.ProductID, VariantID: b.VariantID}.String()
+	})
+
+	results := make([]AdjustmentResult, len(param.Adjustments))
+
+	err := stockTransaction(p.DB, func(tx *gorm.DB) error {
+		for _, position := range positions {
+			adjustment := param.Adjustments[position]
+
+			current, err := applyStockChange(tx, stockChange{
+				ProductID:  adjustment.ProductID,
+				VariantID:  adjustment.VariantID,
+				LocationID: adjustment.LocationID,
+			})
+			if err != nil {
+				return fmt.Errorf("product %v: %v", adjustment.ProductID, err)
+			}
+
+			before := current.Available
+			delta := 0
+			if adjustment.Delta != nil {
+				delta = *adjustment.Delta
+			} else {
+				delta = *adjustment.AbsoluteCount - before
+			}
+
+			level, err := applyStockChange(tx, stockChange{
+				ProductID:  adjustment.ProductID,
+				VariantID:  adjustment.VariantID,
+				LocationID: current.LocationID,
+				Delta:      delta,
+				Reason:     MovementReason(adjustment.Reason),
+				UserID:     userId,
+				Note:       adjustment.Note,
+			})
+
+			if err != nil {
+				return err
+			}
+
+			results[position] = AdjustmentResult{
+				ProductID:  adjustment.ProductID,
+				VariantID:  adjustment.VariantID,
+				LocationID: level.LocationID,
+				Before:     before,
+				After:      level.Available,
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func (p ProductService) GetStockLedger(id uuid.UUID) (*StockLedger, error) {
+	product, err := p.GetProductById(id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var movements []StockMovement
+
+	if result := p.DB.Where("product_id = ?", id).Order("created_at desc").Find(&movements); result.Error != nil {
+		return nil, result.Error
+	}
+
+	ledgerQuantity := 0
+	for _, movement := range movements {
+		ledgerQuantity = ledgerQuantity + movement.Delta
+	}
+
+	return &StockLedger{
+		ProductID:      product.ID,
+		Quantity:       product.Quantity,
+		LedgerQuantity: ledgerQuantity,
+		Movements:      movements,
+	}, nil
+}
+
+// BackfillOpeningBalances records an opening adjustment for products whose
+// quantity predates the ledger, so the ledger always sums to Product.Quantity.
+func BackfillOpeningBalances(db *gorm.DB) error {
+	var products []Product
+
+	result := db.Where("quantity <> 0 AND NOT EXISTS (SELECT 1 FROM stock_movements WHERE stock_movements.product_id = products.id)").Find(&products)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	for _, product := range products {
+		movement := StockMovement{
+			ID:            uuid.New(),
+			ProductID:     product.ID,
+			Delta:         product.Quantity,
+			QuantityAfter: product.Quantity,
+			Reason:        MovementAdjustment,
+		}
+
+		if result := db.Create(&movement); result.Error != nil {
+			return result.Error
+		}
+	}
+
+	return nil
+}
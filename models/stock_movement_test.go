@@ -0,0 +1,100 @@
+package models
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func TestStockTransactionAppliesEveryChange(t *testing.T) {
+	first, second := uuid.New(), uuid.New()
+	locationID := uuid.New()
+
+	db, fake := newFakeDB(t, func(query string, args []driver.Value) *fakeRows {
+		switch {
+		case strings.Contains(query, `FROM "products"`):
+			return &fakeRows{
+				Columns: []string{"id", "name", "quantity"},
+				Values:  [][]driver.Value{{args[0], "product", int64(5)}},
+			}
+		case strings.Contains(query, `FROM "locations"`):
+			return &fakeRows{Columns: []string{"id"}, Values: [][]driver.Value{{args[0]}}}
+		case strings.Contains(query, "count(*)"):
+			return &fakeRows{Columns: []string{"count"}, Values: [][]driver.Value{{int64(0)}}}
+		case strings.Contains(query, `FROM "product_stocks"`):
+			return &fakeRows{
+				Columns: []string{"id", "product_id", "location_id", "quantity"},
+				Values:  [][]driver.Value{{uuid.NewString(), args[0], args[2], int64(5)}},
+			}
+		}
+
+		return nil
+	})
+
+	for len(stockChecks) > 0 {
+		<-stockChecks
+	}
+
+	err := stockTransaction(db, func(tx *gorm.DB) error {
+		for _, id := range []uuid.UUID{first, second} {
+			_, err := applyStockChange(tx, stockChange{
+				ProductID:  id,
+				LocationID: locationID,
+				Delta:      2,
+				Reason:     MovementRestock,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("stockTransaction: %v", err)
+	}
+
+	movements := fake.Matching(`INSERT INTO "stock_movements"`)
+	if len(movements) != 2 {
+		t.Fatalf("got %d ledger inserts, want 2", len(movements))
+	}
+
+	for i, id := range []uuid.UUID{first, second} {
+		if !hasArg(movements[i].Args, id.String()) {
+			t.Errorf("ledger insert %d does not reference product %v", i, id)
+		}
+		if !hasArg(movements[i].Args, int64(7)) {
+			t.Errorf("ledger insert %d does not record quantity_after 7", i)
+		}
+	}
+
+	if got := len(fake.Matching(`UPDATE "products"`)); got != 2 {
+		t.Errorf("got %d product updates, want 2", got)
+	}
+
+	if got := len(fake.Matching(`UPDATE "product_stocks"`)); got != 2 {
+		t.Errorf("got %d stock updates, want 2", got)
+	}
+
+	queued := map[uuid.UUID]bool{}
+	for len(stockChecks) > 0 {
+		queued[<-stockChecks] = true
+	}
+
+	if !queued[first] || !queued[second] {
+		t.Errorf("stock checks queued for %v, want both products", queued)
+	}
+}
+
+func hasArg(args []driver.Value, want driver.Value) bool {
+	for _, arg := range args {
+		if arg == want {
+			return true
+		}
+	}
+
+	return false
+}
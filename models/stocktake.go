@@ -0,0 +1,383 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/loyalsfc/investrite/data"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type StocktakeStatus string
+
+const (
+	StocktakeOpen      StocktakeStatus = "open"
+	StocktakeClosed    StocktakeStatus = "closed"
+	StocktakeApproved  StocktakeStatus = "approved"
+	StocktakeCancelled StocktakeStatus = "cancelled"
+)
+
+type Stocktake struct {
+	ID         uuid.UUID        `json:"id" gorm:"column:id;unique;not null"`
+	Status     StocktakeStatus  `json:"status" gorm:"column:status;not null"`
+	CategoryID *uuid.UUID       `json:"category_id" gorm:"column:category_id"`
+	LocationID uuid.UUID        `json:"location_id" gorm:"column:location_id"`
+	Note       string           `json:"note" gorm:"column:note"`
+	OpenedBy   uuid.UUID        `json:"opened_by" gorm:"column:opened_by;not null"`
+	ClosedBy   *uuid.UUID       `json:"closed_by" gorm:"column:closed_by"`
+	ClosedAt   *time.Time       `json:"closed_at" gorm:"column:closed_at"`
+	ApprovedBy *uuid.UUID       `json:"approved_by" gorm:"column:approved_by"`
+	ApprovedAt *time.Time       `json:"approved_at" gorm:"column:approved_at"`
+	Counts     []StocktakeCount `json:"counts,omitempty" gorm:"foreignKey:StocktakeID;references:ID"`
+	Lines      []StocktakeLine  `json:"lines,omitempty" gorm:"foreignKey:StocktakeID;references:ID"`
+	gorm.Model
+}
+
+type StocktakeCount struct {
+	ID          uuid.UUID `json:"id" gorm:"column:id;unique;not null"`
+	StocktakeID uuid.UUID `json:"stocktake_id" gorm:"column:stocktake_id;index;not null"`
+	ProductID   uuid.UUID `json:"product_id" gorm:"column:product_id;not null"`
+	VariantID   uuid.UUID `json:"variant_id" gorm:"column:variant_id"`
+	Quantity    int       `json:"quantity" gorm:"column:quantity;not null"`
+	Device      string    `json:"device" gorm:"column:device;not null"`
+	CountedBy   uuid.UUID `json:"counted_by" gorm:"column:counted_by;not null"`
+	gorm.Model
+}
+
+type StocktakeLine struct {
+	ID              uuid.UUID `json:"id" gorm:"column:id;unique;not null"`
+	StocktakeID     uuid.UUID `json:"stocktake_id" gorm:"column:stocktake_id;index;not null"`
+	ProductID       uuid.UUID `json:"product_id" gorm:"column:product_id;not null"`
+	VariantID       uuid.UUID `json:"variant_id" gorm:"column:variant_id"`
+	ProductName     string    `json:"product_name" gorm:"column:product_name;not null"`
+	SystemQuantity  int       `json:"system_quantity" gorm:"column:system_quantity;not null"`
+	CountedQuantity *int      `json:"counted_quantity" gorm:"column:counted_quantity"`
+	Variance        int       `json:"variance" gorm:"column:variance;not null"`
+	AppliedVariance *int      `json:"applied_variance" gorm:"column:applied_variance"`
+	gorm.Model
+}
+
+type StocktakeService struct {
+	DB *gorm.DB
+}
+
+func (s StocktakeService) OpenStocktake(param data.OpenStocktakeParams, userId uuid.UUID) (*Stocktake, error) {
+	if param.CategoryID != nil {
+		if result := s.DB.Where("id = ?", *param.CategoryID).First(&Category{}); result.Error != nil {
+			return nil, errors.New("category id does not exist")
+		}
+	}
+
+	locationID, err := resolveLocationID(s.DB, param.LocationID)
+	if err != nil {
+		return nil, err
+	}
+
+	stocktake := Stocktake{
+		ID:         uuid.New(),
+		Status:     StocktakeOpen,
+		CategoryID: param.CategoryID,
+		LocationID: locationID,
+		Note:       param.Note,
+		OpenedBy:   userId,
+	}
+
+	if result := s.DB.Create(&stocktake); result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &stocktake, nil
+}
+
+func (s StocktakeService) GetStocktakes() ([]Stocktake, error) {
+	var stocktakes []Stocktake
+
+	if result := s.DB.Order("created_at desc").Find(&stocktakes); result.Error != nil {
+		return nil, result.Error
+	}
+
+	return stocktakes, nil
+}
+
+func (s StocktakeService) GetStocktake(id uuid.UUID) (*Stocktake, error) {
+	var stocktake Stocktake
+
+	if result := s.DB.Preload("Counts").Preload("Lines").Where("id = ?", id).First(&stocktake); result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &stocktake, nil
+}
+
+func lockStocktake(tx *gorm.DB, id uuid.UUID, status StocktakeStatus) (*Stocktake, error) {
+	var stocktake Stocktake
+
+	if result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&stocktake); result.Error != nil {
+		return nil, result.Error
+	}
+
+	if stocktake.Status != status {
+		return nil, fmt.Errorf("stocktake is %v, expected %v", stocktake.Status, status)
+	}
+
+	return &stocktake, nil
+}
+
+func stocktakeScope(tx *gorm.DB, stocktake *Stocktake) *gorm.DB {
+	query := tx.Model(&Product{})
+
+	if stocktake.CategoryID != nil {
+		query = query.Where("category_id = ?", *stocktake.CategoryID)
+	}
+
+	return query
+}
+
+func (s StocktakeService) SubmitCounts(id uuid.UUID, param data.SubmitCountsParams, userId uuid.UUID) error {
+	if len(param.Counts) == 0 {
+		return errors.New("no counts provided")
+	}
+
+	device := param.Device
+	if device == "" {
+		device = "default"
+	}
+
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		stocktake, err := lockStocktake(tx, id, StocktakeOpen)
+		if err != nil {
+			return err
+		}
+
+		for _, count := range param.Counts {
+			if count.Quantity < 0 {
+				return fmt.Errorf("invalid count for product with id %v", count.ProductID)
+			}
+
+			var found int64
+			if result := stocktakeScope(tx, stocktake).Where("id = ?", count.ProductID).Count(&found); result.Error != nil {
+				return result.Error
+			}
+
+			if found == 0 {
+				return fmt.Errorf("product %v is not part of this stocktake", count.ProductID)
+			}
+
+			if err := checkVariant(tx, count.ProductID, count.VariantID); err != nil {
+				return err
+			}
+
+			entry := StocktakeCount{
+				ID:          uuid.New(),
+				StocktakeID: id,
+				ProductID:   count.ProductID,
+				VariantID:   count.VariantID,
+				Quantity:    count.Quantity,
+				Device:      device,
+				CountedBy:   userId,
+			}
+
+			if result := tx.Create(&entry); result.Error != nil {
+				return result.Error
+			}
+		}
+
+		return nil
+	})
+}
+
+// CloseStocktake freezes the counts and records a variance line for every
+// product in scope, or for every variant of products that have them. Each
+// device's latest count for a product replaces its earlier ones, and counts
+// from different devices are added together.
+func (s StocktakeService) CloseStocktake(id uuid.UUID, userId uuid.UUID) (*Stocktake, error) {
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		stocktake, err := lockStocktake(tx, id, StocktakeOpen)
+		if err != nil {
+			return err
+		}
+
+		var counts []StocktakeCount
+		if result := tx.Where("stocktake_id = ?", id).Order("created_at asc").Find(&counts); result.Error != nil {
+			return result.Error
+		}
+
+		latest := map[stockKey]map[string]int{}
+		for _, count := range counts {
+			key := stockKey{ProductID: count.ProductID, VariantID: count.VariantID}
+			if latest[key] == nil {
+				latest[key] = map[string]int{}
+			}
+			latest[key][count.Device] = count.Quantity
+		}
+
+		var products []Product
+		if result := stocktakeScope(tx, stocktake).Find(&products); result.Error != nil {
+			return result.Error
+		}
+
+		var stock []ProductStock
+		if result := tx.Where("location_id = ?", stocktake.LocationID).Find(&stock); result.Error != nil {
+			return result.Error
+		}
+
+		system := map[stockKey]int{}
+		for _, level := range stock {
+			system[stockKey{ProductID: level.ProductID, VariantID: level.VariantID}] = level.Quantity
+		}
+
+		var lines []StocktakeLine
+		for _, product := range products {
+			var variants []ProductVariant
+			if result := tx.Where("product_id = ?", product.ID).Order("created_at asc").Find(&variants); result.Error != nil {
+				return result.Error
+			}
+
+			if len(variants) == 0 {
+				lines = append(lines, StocktakeLine{ProductID: product.ID, ProductName: product.Name})
+				continue
+			}
+
+			options, err := productOptions(tx, product.ID)
+			if err != nil {
+				return err
+			}
+
+			for _, variant := range variants {
+				lines = append(lines, StocktakeLine{
+					ProductID:   product.ID,
+					VariantID:   variant.ID,
+					ProductName: product.Name + " (" + variant.Options.Label(options) + ")",
+				})
+			}
+		}
+
+		for _, line := range lines {
+			key := stockKey{ProductID: line.ProductID, VariantID: line.VariantID}
+			line.ID = uuid.New()
+			line.StocktakeID = id
+			line.SystemQuantity = system[key]
+
+			if devices, ok := latest[key]; ok {
+				counted := 0
+				for _, quantity := range devices {
+					counted = counted + quantity
+				}
+
+				line.CountedQuantity = &counted
+				line.Variance = counted - line.SystemQuantity
+			}
+
+			if result := tx.Create(&line); result.Error != nil {
+				return result.Error
+			}
+		}
+
+		now := time.Now()
+		result := tx.Model(&Stocktake{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"status":    StocktakeClosed,
+			"closed_by": userId,
+			"closed_at": now,
+		})
+
+		return result.Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetStocktake(id)
+}
+
+// ApproveStocktake posts the variances recorded at close as adjustments, so
+// sales made between closing and approval are not overwritten. A shortage
+// larger than what is left is clamped to zero stock, and each line records
+// the variance that was actually applied.
+func (s StocktakeService) ApproveStocktake(id uuid.UUID, userId uuid.UUID) (*Stocktake, error) {
+	err := stockTransaction(s.DB, func(tx *gorm.DB) error {
+		stocktake, err := lockStocktake(tx, id, StocktakeClosed)
+		if err != nil {
+			return err
+		}
+
+		var lines []StocktakeLine
+		result := tx.Where("stocktake_id = ? AND counted_quantity IS NOT NULL AND variance <> 0", id).
+			Order("product_id asc, variant_id asc").
+			Find(&lines)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		for _, line := range lines {
+			current, err := applyStockChange(tx, stockChange{
+				ProductID:  line.ProductID,
+				VariantID:  line.VariantID,
+				LocationID: stocktake.LocationID,
+			})
+			if err != nil {
+				return err
+			}
+
+			delta := line.Variance
+			if current.Available+delta < 0 {
+				delta = -current.Available
+			}
+
+			if delta != 0 {
+				_, err := applyStockChange(tx, stockChange{
+					ProductID:   line.ProductID,
+					VariantID:   line.VariantID,
+					LocationID:  stocktake.LocationID,
+					Delta:       delta,
+					Reason:      MovementAdjustment,
+					ReferenceID: id,
+					UserID:      userId,
+					Note:        "stocktake",
+				})
+
+				if err != nil {
+					return err
+				}
+			}
+
+			if result := tx.Model(&StocktakeLine{}).Where("id = ?", line.ID).Update("applied_variance", delta); result.Error != nil {
+				return result.Error
+			}
+		}
+
+		now := time.Now()
+		result = tx.Model(&Stocktake{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"status":      StocktakeApproved,
+			"approved_by": userId,
+			"approved_at": now,
+		})
+
+		return result.Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetStocktake(id)
+}
+
+func (s StocktakeService) CancelStocktake(id uuid.UUID) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		var stocktake Stocktake
+
+		if result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&stocktake); result.Error != nil {
+			return result.Error
+		}
+
+		if stocktake.Status != StocktakeOpen && stocktake.Status != StocktakeClosed {
+			return fmt.Errorf("cannot cancel a stocktake that is %v", stocktake.Status)
+		}
+
+		return tx.Model(&Stocktake{}).Where("id = ?", id).Update("status", StocktakeCancelled).Error
+	})
+}
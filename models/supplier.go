@@ -0,0 +1,115 @@
+package models
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/loyalsfc/investrite/data"
+	"gorm.io/gorm"
+)
+
+type Supplier struct {
+	ID          uuid.UUID `json:"id" gorm:"column:id;unique;not null"`
+	Name        string    `json:"name" gorm:"column:name;unique;not null"`
+	ContactName string    `json:"contact_name" gorm:"column:contact_name"`
+	Email       string    `json:"email" gorm:"column:email"`
+	Phone       string    `json:"phone" gorm:"column:phone"`
+	Address     string    `json:"address" gorm:"column:address"`
+	gorm.Model
+}
+
+type SupplierService struct {
+	DB *gorm.DB
+}
+
+func (s SupplierService) CreateSupplier(param data.SupplierParams) (*Supplier, error) {
+	if len(param.Name) < 3 {
+		return nil, errors.New("supplier name cannot be less than 3")
+	}
+
+	if result := s.DB.Where("name = ?", param.Name).First(&Supplier{}); result.Error == nil {
+		return nil, errors.New("supplier already exist")
+	}
+
+	supplier := Supplier{
+		ID:          uuid.New(),
+		Name:        param.Name,
+		ContactName: param.ContactName,
+		Email:       param.Email,
+		Phone:       param.Phone,
+		Address:     param.Address,
+	}
+
+	if result := s.DB.Create(&supplier); result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &supplier, nil
+}
+
+func (s SupplierService) GetSuppliers() ([]Supplier, error) {
+	var suppliers []Supplier
+
+	if result := s.DB.Find(&suppliers); result.Error != nil {
+		return nil, result.Error
+	}
+
+	return suppliers, nil
+}
+
+func (s SupplierService) GetSupplierById(id uuid.UUID) (*Supplier, error) {
+	var supplier Supplier
+
+	if result := s.DB.Where("id = ?", id).First(&supplier); result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &supplier, nil
+}
+
+func (s SupplierService) UpdateSupplier(id uuid.UUID, param data.SupplierParams) error {
+	if len(param.Name) < 3 {
+		return errors.New("supplier name cannot be less than 3")
+	}
+
+	supplier, err := s.GetSupplierById(id)
+	if err != nil {
+		return err
+	}
+
+	supplier.Name = param.Name
+	supplier.ContactName = param.ContactName
+	supplier.Email = param.Email
+	supplier.Phone = param.Phone
+	supplier.Address = param.Address
+
+	if result := s.DB.Save(supplier); result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
+
+func (s SupplierService) DeleteSupplier(id uuid.UUID) error {
+	if _, err := s.GetSupplierById(id); err != nil {
+		return err
+	}
+
+	var openOrders int64
+	result := s.DB.Model(&PurchaseOrder{}).
+		Where("supplier_id = ? AND status IN ?", id, []PurchaseOrderStatus{PODraft, POSubmitted, POPartiallyReceived}).
+		Count(&openOrders)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if openOrders > 0 {
+		return errors.New("supplier has open purchase orders")
+	}
+
+	if result := s.DB.Where("id = ?", id).Delete(&Supplier{}); result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
@@ -0,0 +1,220 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/loyalsfc/investrite/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const refreshTokenTTL = 30 * 24 * time.Hour
+
+// RefreshToken is stored as a hash so a leaked table cannot be replayed. Each
+// refresh replaces the token with a new one in the same family; presenting a
+// token that was already replaced revokes the whole family, since either the
+// client or an attacker is holding a stolen copy.
+type RefreshToken struct {
+	ID         uuid.UUID  `json:"id" gorm:"column:id;unique;not null"`
+	UserID     uuid.UUID  `json:"user_id" gorm:"column:user_id;index;not null"`
+	FamilyID   uuid.UUID  `json:"family_id" gorm:"column:family_id;index;not null"`
+	TokenHash  string     `json:"-" gorm:"column:token_hash;unique;not null"`
+	ExpiresAt  time.Time  `json:"expires_at" gorm:"column:expires_at;not null"`
+	RevokedAt  *time.Time `json:"revoked_at" gorm:"column:revoked_at"`
+	ReplacedBy uuid.UUID  `json:"replaced_by" gorm:"column:replaced_by"`
+	gorm.Model
+}
+
+// RevokedToken blocks an access token until it would have expired anyway.
+type RevokedToken struct {
+	JTI       string    `json:"jti" gorm:"column:jti;unique;not null"`
+	UserID    uuid.UUID `json:"user_id" gorm:"column:user_id;not null"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"column:expires_at;index;not null"`
+	gorm.Model
+}
+
+type TokenPair struct {
+	AccessToken      string    `json:"access_token"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+type TokenService struct {
+	DB *gorm.DB
+}
+
+var errInvalidRefreshToken = errors.New("invalid or expired refresh token")
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newRefreshToken(tx *gorm.DB, userID uuid.UUID, familyID uuid.UUID) (*RefreshToken, string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(secret)
+
+	refresh := RefreshToken{
+		ID:        uuid.New(),
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(refreshTokenTTL),
+	}
+
+	if result := tx.Create(&refresh); result.Error != nil {
+		return nil, "", result.Error
+	}
+
+	return &refresh, token, nil
+}
+
+func issueTokens(tx *gorm.DB, userID uuid.UUID, familyID uuid.UUID) (*TokenPair, *RefreshToken, error) {
+	access, claims, err := utils.GenerateToken(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	refresh, token, err := newRefreshToken(tx, userID, familyID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:      access,
+		ExpiresAt:        claims.ExpiresAt,
+		RefreshToken:     token,
+		RefreshExpiresAt: refresh.ExpiresAt,
+	}, refresh, nil
+}
+
+// IssueTokens starts a new session for a user who has just signed in.
+func (t TokenService) IssueTokens(userID uuid.UUID) (*TokenPair, error) {
+	pair, _, err := issueTokens(t.DB, userID, uuid.New())
+	if err != nil {
+		return nil, err
+	}
+
+	return pair, nil
+}
+
+// Refresh trades a refresh token for a new token pair and retires the old
+// refresh token.
+func (t TokenService) Refresh(token string) (*TokenPair, error) {
+	var pair *TokenPair
+	var reusedFamily uuid.UUID
+
+	err := t.DB.Transaction(func(tx *gorm.DB) error {
+		var current RefreshToken
+
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("token_hash = ?", hashToken(token)).First(&current)
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return errInvalidRefreshToken
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if current.RevokedAt != nil {
+			if current.ReplacedBy != uuid.Nil {
+				reusedFamily = current.FamilyID
+			}
+			return errInvalidRefreshToken
+		}
+
+		if time.Now().After(current.ExpiresAt) {
+			return errInvalidRefreshToken
+		}
+
+		var next *RefreshToken
+		var err error
+
+		pair, next, err = issueTokens(tx, current.UserID, current.FamilyID)
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&RefreshToken{}).Where("id = ?", current.ID).Updates(map[string]interface{}{
+			"revoked_at":  time.Now(),
+			"replaced_by": next.ID,
+		}).Error
+	})
+
+	//Revoke outside the failed transaction so the revocation sticks
+	if reusedFamily != uuid.Nil {
+		if err := t.revokeFamily(reusedFamily); err != nil {
+			return nil, err
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return pair, nil
+}
+
+func (t TokenService) revokeFamily(familyID uuid.UUID) error {
+	return t.DB.Model(&RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// Logout revokes the access token in use and the session behind the given
+// refresh token, or every session of the user when all is set.
+func (t TokenService) Logout(claims *utils.TokenClaims, refreshToken string, all bool) error {
+	return t.DB.Transaction(func(tx *gorm.DB) error {
+		revoked := RevokedToken{
+			JTI:       claims.ID,
+			UserID:    claims.UserID,
+			ExpiresAt: claims.ExpiresAt,
+		}
+
+		if result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&revoked); result.Error != nil {
+			return result.Error
+		}
+
+		//Entries past their expiry no longer need to be remembered
+		if result := tx.Unscoped().Where("expires_at < ?", time.Now()).Delete(&RevokedToken{}); result.Error != nil {
+			return result.Error
+		}
+
+		query := tx.Model(&RefreshToken{}).Where("user_id = ? AND revoked_at IS NULL", claims.UserID)
+
+		if !all {
+			if refreshToken == "" {
+				return nil
+			}
+
+			var current RefreshToken
+			if result := tx.Where("token_hash = ? AND user_id = ?", hashToken(refreshToken), claims.UserID).First(&current); result.Error != nil {
+				return errInvalidRefreshToken
+			}
+
+			query = query.Where("family_id = ?", current.FamilyID)
+		}
+
+		return query.Update("revoked_at", time.Now()).Error
+	})
+}
+
+func (t TokenService) IsRevoked(jti string) (bool, error) {
+	var count int64
+
+	if result := t.DB.Model(&RevokedToken{}).Where("jti = ?", jti).Count(&count); result.Error != nil {
+		return false, result.Error
+	}
+
+	return count > 0, nil
+}
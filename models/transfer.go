@@ -0,0 +1,276 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/loyalsfc/investrite/data"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TransferStatus string
+
+const (
+	TransferRequested TransferStatus = "requested"
+	TransferInTransit TransferStatus = "in_transit"
+	TransferReceived  TransferStatus = "received"
+	TransferCancelled TransferStatus = "cancelled"
+)
+
+type StockTransfer struct {
+	ID                    uuid.UUID      `json:"id" gorm:"column:id;unique;not null"`
+	SourceLocationID      uuid.UUID      `json:"source_location_id" gorm:"column:source_location_id;index;not null"`
+	DestinationLocationID uuid.UUID      `json:"destination_location_id" gorm:"column:destination_location_id;index;not null"`
+	Status                TransferStatus `json:"status" gorm:"column:status;not null"`
+	Note                  string         `json:"note" gorm:"column:note"`
+	RequestedBy           uuid.UUID      `json:"requested_by" gorm:"column:requested_by;not null"`
+	DispatchedBy          *uuid.UUID     `json:"dispatched_by" gorm:"column:dispatched_by"`
+	DispatchedAt          *time.Time     `json:"dispatched_at" gorm:"column:dispatched_at"`
+	ReceivedBy            *uuid.UUID     `json:"received_by" gorm:"column:received_by"`
+	ReceivedAt            *time.Time     `json:"received_at" gorm:"column:received_at"`
+	Lines                 []TransferLine `json:"lines" gorm:"foreignKey:TransferID;references:ID"`
+	gorm.Model
+}
+
+type TransferLine struct {
+	ID               uuid.UUID `json:"id" gorm:"column:id;unique;not null"`
+	TransferID       uuid.UUID `json:"transfer_id" gorm:"column:transfer_id;index;not null"`
+	ProductID        uuid.UUID `json:"product_id" gorm:"column:product_id;not null"`
+	VariantID        uuid.UUID `json:"variant_id" gorm:"column:variant_id"`
+	Quantity         int       `json:"quantity" gorm:"column:quantity;not null"`
+	QuantityReceived int       `json:"quantity_received" gorm:"column:quantity_received;default:0;not null"`
+	Discrepancy      int       `json:"discrepancy" gorm:"column:discrepancy;default:0;not null"`
+	DiscrepancyNote  string    `json:"discrepancy_note" gorm:"column:discrepancy_note"`
+	gorm.Model
+}
+
+type TransferService struct {
+	DB *gorm.DB
+}
+
+func (t TransferService) RequestTransfer(param data.TransferParams, userId uuid.UUID) (*StockTransfer, error) {
+	if param.SourceLocationID == param.DestinationLocationID {
+		return nil, errors.New("source and destination must be different locations")
+	}
+
+	if len(param.Lines) == 0 {
+		return nil, errors.New("transfer must contain at least one line")
+	}
+
+	for _, id := range []uuid.UUID{param.SourceLocationID, param.DestinationLocationID} {
+		if result := t.DB.Where("id = ?", id).First(&Location{}); result.Error != nil {
+			return nil, fmt.Errorf("location %v does not exist", id)
+		}
+	}
+
+	transfer := StockTransfer{
+		ID:                    uuid.New(),
+		SourceLocationID:      param.SourceLocationID,
+		DestinationLocationID: param.DestinationLocationID,
+		Status:                TransferRequested,
+		Note:                  param.Note,
+		RequestedBy:           userId,
+	}
+
+	for _, line := range param.Lines {
+		if line.Quantity < 1 {
+			return nil, fmt.Errorf("invalid quantity for product with id %v", line.ProductID)
+		}
+
+		if result := t.DB.Where("id = ?", line.ProductID).First(&Product{}); result.Error != nil {
+			return nil, fmt.Errorf("no product found for id %v", line.ProductID)
+		}
+
+		if err := checkVariant(t.DB, line.ProductID, line.VariantID); err != nil {
+			return nil, err
+		}
+
+		transfer.Lines = append(transfer.Lines, TransferLine{
+			ID:         uuid.New(),
+			TransferID: transfer.ID,
+			ProductID:  line.ProductID,
+			VariantID:  line.VariantID,
+			Quantity:   line.Quantity,
+		})
+	}
+
+	if result := t.DB.Create(&transfer); result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &transfer, nil
+}
+
+func (t TransferService) GetTransfers() ([]StockTransfer, error) {
+	var transfers []StockTransfer
+
+	if result := t.DB.Preload("Lines").Order("created_at desc").Find(&transfers); result.Error != nil {
+		return nil, result.Error
+	}
+
+	return transfers, nil
+}
+
+func (t TransferService) GetTransfer(id uuid.UUID) (*StockTransfer, error) {
+	var transfer StockTransfer
+
+	if result := t.DB.Preload("Lines").Where("id = ?", id).First(&transfer); result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &transfer, nil
+}
+
+func lockTransfer(tx *gorm.DB, id uuid.UUID, status TransferStatus) (*StockTransfer, error) {
+	var transfer StockTransfer
+
+	if result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&transfer); result.Error != nil {
+		return nil, result.Error
+	}
+
+	if transfer.Status != status {
+		return nil, fmt.Errorf("transfer is %v, expected %v", transfer.Status, status)
+	}
+
+	if result := tx.Where("transfer_id = ?", id).Find(&transfer.Lines); result.Error != nil {
+		return nil, result.Error
+	}
+
+	//Lines are applied in this order, so keep it to the stock lock order
+	sort.SliceStable(transfer.Lines, func(i, j int) bool {
+		a := stockKey{ProductID: transfer.Lines[i].ProductID, VariantID: transfer.Lines[i].VariantID}
+		b := stockKey{ProductID: transfer.Lines[j].ProductID, VariantID: transfer.Lines[j].VariantID}
+		return a.String() < b.String()
+	})
+
+	return &transfer, nil
+}
+
+func (t TransferService) DispatchTransfer(id uuid.UUID, userId uuid.UUID) (*StockTransfer, error) {
+	err := stockTransaction(t.DB, func(tx *gorm.DB) error {
+		transfer, err := lockTransfer(tx, id, TransferRequested)
+		if err != nil {
+			return err
+		}
+
+		for _, line := range transfer.Lines {
+			_, err := applyStockChange(tx, stockChange{
+				ProductID:   line.ProductID,
+				VariantID:   line.VariantID,
+				LocationID:  transfer.SourceLocationID,
+				Delta:       -line.Quantity,
+				Reason:      MovementTransferOut,
+				ReferenceID: transfer.ID,
+				UserID:      userId,
+			})
+
+			if err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		result := tx.Model(&StockTransfer{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"status":        TransferInTransit,
+			"dispatched_by": userId,
+			"dispatched_at": now,
+		})
+
+		return result.Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return t.GetTransfer(id)
+}
+
+// ReceiveTransfer books what actually arrived at the destination. Lines left
+// out of the request are taken as received in full; any shortfall is kept on
+// the line as a discrepancy.
+func (t TransferService) ReceiveTransfer(id uuid.UUID, param data.ReceiveTransferParams, userId uuid.UUID) (*StockTransfer, error) {
+	err := stockTransaction(t.DB, func(tx *gorm.DB) error {
+		transfer, err := lockTransfer(tx, id, TransferInTransit)
+		if err != nil {
+			return err
+		}
+
+		received := map[uuid.UUID]data.ReceiveTransferLineParams{}
+		for _, line := range param.Lines {
+			received[line.LineID] = line
+		}
+
+		for _, line := range transfer.Lines {
+			quantity := line.Quantity
+			note := ""
+
+			if entry, ok := received[line.ID]; ok {
+				if entry.Quantity < 0 || entry.Quantity > line.Quantity {
+					return fmt.Errorf("invalid received quantity for line %v", line.ID)
+				}
+
+				quantity = entry.Quantity
+				note = entry.Note
+				delete(received, line.ID)
+			}
+
+			result := tx.Model(&TransferLine{}).Where("id = ?", line.ID).Updates(map[string]interface{}{
+				"quantity_received": quantity,
+				"discrepancy":       line.Quantity - quantity,
+				"discrepancy_note":  note,
+			})
+			if result.Error != nil {
+				return result.Error
+			}
+
+			_, err := applyStockChange(tx, stockChange{
+				ProductID:   line.ProductID,
+				VariantID:   line.VariantID,
+				LocationID:  transfer.DestinationLocationID,
+				Delta:       quantity,
+				Reason:      MovementTransferIn,
+				ReferenceID: transfer.ID,
+				UserID:      userId,
+				Note:        note,
+			})
+
+			if err != nil {
+				return err
+			}
+		}
+
+		for lineID := range received {
+			return fmt.Errorf("line %v does not belong to this transfer", lineID)
+		}
+
+		now := time.Now()
+		result := tx.Model(&StockTransfer{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"status":      TransferReceived,
+			"received_by": userId,
+			"received_at": now,
+		})
+
+		return result.Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return t.GetTransfer(id)
+}
+
+func (t TransferService) CancelTransfer(id uuid.UUID) error {
+	return t.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := lockTransfer(tx, id, TransferRequested); err != nil {
+			return err
+		}
+
+		return tx.Model(&StockTransfer{}).Where("id = ?", id).Update("status", TransferCancelled).Error
+	})
+}
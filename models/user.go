@@ -2,6 +2,7 @@ package models
 
 import (
 	"errors"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/loyalsfc/investrite/data"
@@ -21,6 +22,8 @@ type User struct {
 	Password  string    `json:"password" gorm:"column:password;not nul"`
 	Role      string    `json:"role" gorm:"column:role"`
 	UserID    uuid.UUID `json:"user_id" gorm:"column:user_id;unique;not null"`
+
+	EmailVerifiedAt *time.Time `json:"email_verified_at" gorm:"column:email_verified_at"`
 }
 
 type APIUser struct {
@@ -29,6 +32,8 @@ type APIUser struct {
 	Email     string    `json:"email"`
 	Role      string    `json:"role"`
 	UserID    uuid.UUID `json:"user_id"`
+
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
 }
 
 func (u UserService) CreateUser(form data.FormData) (*User, error) {
@@ -58,19 +63,46 @@ func (u UserService) CreateUser(form data.FormData) (*User, error) {
 	return &user, nil
 }
 
-func (u User) BeforeCreate(tx *gorm.DB) (err error) {
-	tx.Model(u).Update("role", utils.ViewerRole)
+// BeforeCreate gives self-registered users the lowest role. Invited users
+// arrive with their role already set and keep it.
+func (u *User) BeforeCreate(tx *gorm.DB) (err error) {
+	if u.Role == "" {
+		u.Role = string(utils.ViewerRole)
+	}
 	return
 }
 
-func (u UserService) GetUsers() ([]APIUser, error) {
+var userSort = listSort{
+	Columns: map[string]string{
+		"first_name": "first_name",
+		"last_name":  "last_name",
+		"email":      "email",
+		"role":       "role",
+		"created_at": "created_at",
+	},
+	DefaultKey:   "created_at",
+	DefaultOrder: "asc",
+}
+
+func userQuery(db *gorm.DB, filter data.UserFilter) *gorm.DB {
+	query := db.Model(&User{})
+
+	if filter.Role != "" {
+		query = query.Where("role = ?", filter.Role)
+	}
+
+	return query
+}
+
+func (u UserService) GetUsers(filter data.UserFilter) ([]APIUser, *ListMeta, error) {
 	var users []APIUser
 
-	if result := u.DB.Model(&User{}).Find(&users); result.Error != nil {
-		return nil, result.Error
+	meta, err := paginate(userQuery(u.DB, filter), filter.ListParams, userSort, &users)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return users, nil
+	return users, meta, nil
 }
 
 func (u UserService) IsUserExist(email string) bool {
@@ -112,7 +144,7 @@ func (u UserService) UpdateUserRole(email string, newRole utils.UserRole) error
 		return changedUserErr
 	}
 
-	if isValidRole := utils.IsValidRole(newRole); !isValidRole {
+	if roleExists := (RoleService{DB: u.DB}).RoleExists(string(newRole)); !roleExists {
 		return errors.New("the provided role is invalid")
 	}
 
@@ -0,0 +1,234 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/loyalsfc/investrite/data"
+)
+
+type ProductValuation struct {
+	ProductID    uuid.UUID `json:"product_id"`
+	ProductName  string    `json:"product_name"`
+	CategoryID   uuid.UUID `json:"category_id"`
+	CategoryName string    `json:"category_name"`
+	Quantity     int       `json:"quantity"`
+	UnitCost     int       `json:"unit_cost"`
+	CostValue    int       `json:"cost_value"`
+	RetailValue  int       `json:"retail_value"`
+}
+
+type CategoryValuation struct {
+	CategoryID   uuid.UUID `json:"category_id"`
+	CategoryName string    `json:"category_name"`
+	Quantity     int       `json:"quantity"`
+	CostValue    int       `json:"cost_value"`
+	RetailValue  int       `json:"retail_value"`
+}
+
+type SalesMargin struct {
+	From          string  `json:"from"`
+	To            string  `json:"to"`
+	Revenue       int     `json:"revenue"`
+	CostOfGoods   int     `json:"cost_of_goods"`
+	GrossMargin   int     `json:"gross_margin"`
+	MarginPercent float64 `json:"margin_percent"`
+}
+
+type InventoryValuation struct {
+	Method      string              `json:"method"`
+	Quantity    int                 `json:"quantity"`
+	CostValue   int                 `json:"cost_value"`
+	RetailValue int                 `json:"retail_value"`
+	Products    []ProductValuation  `json:"products"`
+	Categories  []CategoryValuation `json:"categories"`
+	Margin      SalesMargin         `json:"margin"`
+}
+
+// InventoryValuation values stock on hand at cost and at retail. FIFO uses
+// what is left of each cost layer; average prices every unit at the weighted
+// average cost of everything received. Stock without layers falls back to the
+// product's cost price.
+func (r ReportService) InventoryValuation(param data.ValuationParams) (*InventoryValuation, error) {
+	method := param.Method
+	if method == "" {
+		method = "fifo"
+	}
+
+	if method != "fifo" && method != "average" {
+		return nil, errors.New("method must be fifo or average")
+	}
+
+	timezone := param.Timezone
+	if timezone == "" {
+		timezone = "UTC"
+	}
+
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %v", timezone)
+	}
+
+	start, end, err := reportRange(param.From, param.To, location)
+	if err != nil {
+		return nil, err
+	}
+
+	var products []struct {
+		Product
+		CategoryName string
+	}
+
+	result := r.DB.Model(&Product{}).
+		Select("products.*, categories.name AS category_name").
+		Joins("LEFT JOIN categories ON categories.id = products.category_id").
+		Order("products.name asc").
+		Scan(&products)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	var layers []struct {
+		ProductID    uuid.UUID
+		Remaining    int
+		FifoValue    int
+		Received     int
+		ReceivedCost int
+	}
+
+	result = r.DB.Model(&CostLayer{}).
+		Select("product_id, SUM(remaining) AS remaining, SUM(remaining * unit_cost) AS fifo_value, SUM(quantity) AS received, SUM(quantity * unit_cost) AS received_cost").
+		Group("product_id").
+		Scan(&layers)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	var variantRetail []struct {
+		ProductID uuid.UUID
+		Retail    int
+	}
+
+	result = r.DB.Model(&ProductVariant{}).
+		Select("product_variants.product_id, SUM(product_variants.quantity * coalesce(product_variants.price, products.price)) AS retail").
+		Joins("JOIN products ON products.id = product_variants.product_id").
+		Group("product_variants.product_id").
+		Scan(&variantRetail)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	layersFor := map[uuid.UUID]int{}
+	for i, layer := range layers {
+		layersFor[layer.ProductID] = i
+	}
+
+	retailFor := map[uuid.UUID]int{}
+	for _, retail := range variantRetail {
+		retailFor[retail.ProductID] = retail.Retail
+	}
+
+	report := InventoryValuation{Method: method}
+	categories := map[uuid.UUID]*CategoryValuation{}
+
+	for _, product := range products {
+		valuation := ProductValuation{
+			ProductID:    product.ID,
+			ProductName:  product.Name,
+			CategoryID:   product.CategoryId,
+			CategoryName: product.CategoryName,
+			Quantity:     product.Quantity,
+			UnitCost:     product.CostPrice,
+			RetailValue:  product.Quantity * product.Price,
+		}
+
+		if retail, ok := retailFor[product.ID]; ok {
+			valuation.RetailValue = retail
+		}
+
+		valuation.CostValue = valuation.Quantity * valuation.UnitCost
+
+		if i, ok := layersFor[product.ID]; ok {
+			layer := layers[i]
+
+			switch method {
+			case "fifo":
+				valuation.CostValue = layer.FifoValue + (valuation.Quantity-layer.Remaining)*product.CostPrice
+				if valuation.Quantity > 0 {
+					valuation.UnitCost = valuation.CostValue / valuation.Quantity
+				}
+			case "average":
+				if layer.Received > 0 {
+					valuation.UnitCost = layer.ReceivedCost / layer.Received
+					valuation.CostValue = layer.ReceivedCost * valuation.Quantity / layer.Received
+				}
+			}
+		}
+
+		report.Products = append(report.Products, valuation)
+		report.Quantity = report.Quantity + valuation.Quantity
+		report.CostValue = report.CostValue + valuation.CostValue
+		report.RetailValue = report.RetailValue + valuation.RetailValue
+
+		category, ok := categories[product.CategoryId]
+		if !ok {
+			category = &CategoryValuation{CategoryID: product.CategoryId, CategoryName: product.CategoryName}
+			categories[product.CategoryId] = category
+		}
+
+		category.Quantity = category.Quantity + valuation.Quantity
+		category.CostValue = category.CostValue + valuation.CostValue
+		category.RetailValue = category.RetailValue + valuation.RetailValue
+	}
+
+	for _, category := range categories {
+		report.Categories = append(report.Categories, *category)
+	}
+
+	sort.Slice(report.Categories, func(i, j int) bool {
+		return report.Categories[i].CategoryName < report.Categories[j].CategoryName
+	})
+
+	margin, err := r.salesMargin(start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	report.Margin = *margin
+	report.Margin.From = start.Format("2006-01-02")
+	report.Margin.To = end.AddDate(0, 0, -1).Format("2006-01-02")
+
+	return &report, nil
+}
+
+// salesMargin compares revenue with cost of goods sold for items still kept
+// by the customer; refunded units are taken out of both sides.
+func (r ReportService) salesMargin(start time.Time, end time.Time) (*SalesMargin, error) {
+	var margin SalesMargin
+
+	result := r.DB.Raw(`SELECT
+			coalesce(sum((order_items.quantity - order_items.refunded_quantity) * order_items.unit_price), 0)::bigint AS revenue,
+			coalesce(sum(order_items.cost_total * (order_items.quantity - order_items.refunded_quantity) / order_items.quantity), 0)::bigint AS cost_of_goods
+		FROM order_items
+		JOIN orders ON orders.order_id = order_items.order_id
+		WHERE orders.deleted_at IS NULL AND order_items.deleted_at IS NULL AND order_items.quantity > 0
+			AND orders.status IN @statuses AND orders.created_at >= @start AND orders.created_at < @end`,
+		map[string]interface{}{
+			"statuses": []Status{completed, refunded, partiallyRefunded},
+			"start":    start,
+			"end":      end,
+		}).Scan(&margin)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	margin.GrossMargin = margin.Revenue - margin.CostOfGoods
+	if margin.Revenue > 0 {
+		margin.MarginPercent = float64(margin.GrossMargin) * 100 / float64(margin.Revenue)
+	}
+
+	return &margin, nil
+}
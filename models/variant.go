@@ -0,0 +1,446 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/loyalsfc/investrite/data"
+	"gorm.io/gorm"
+)
+
+type StringList []string
+
+func (s StringList) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+func (s *StringList) Scan(value interface{}) error {
+	return scanJSON(value, s)
+}
+
+type OptionValues map[string]string
+
+func (o OptionValues) Value() (driver.Value, error) {
+	return json.Marshal(o)
+}
+
+func (o *OptionValues) Scan(value interface{}) error {
+	return scanJSON(value, o)
+}
+
+func scanJSON(value interface{}, dest interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(v, dest)
+	case string:
+		return json.Unmarshal([]byte(v), dest)
+	default:
+		return fmt.Errorf("cannot scan %T into json column", value)
+	}
+}
+
+// Label joins the option values in dimension order, e.g. "M / Red".
+func (o OptionValues) Label(options []ProductOption) string {
+	var parts []string
+
+	for _, option := range options {
+		if value, ok := o[option.Name]; ok {
+			parts = append(parts, value)
+		}
+	}
+
+	if len(parts) == 0 {
+		keys := make([]string, 0, len(o))
+		for key := range o {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			parts = append(parts, o[key])
+		}
+	}
+
+	return strings.Join(parts, " / ")
+}
+
+type ProductOption struct {
+	ID        uuid.UUID  `json:"id" gorm:"column:id;unique;not null"`
+	ProductID uuid.UUID  `json:"product_id" gorm:"column:product_id;index;not null"`
+	Name      string     `json:"name" gorm:"column:name;not null"`
+	Values    StringList `json:"values" gorm:"column:values;type:jsonb;not null"`
+	Position  int        `json:"position" gorm:"column:position;not null"`
+	gorm.Model
+}
+
+type ProductVariant struct {
+	ID        uuid.UUID    `json:"id" gorm:"column:id;unique;not null"`
+	ProductID uuid.UUID    `json:"product_id" gorm:"column:product_id;index;not null"`
+	SKU       string       `json:"sku" gorm:"column:sku;index:idx_product_variants_sku,unique,where:sku <> '' AND deleted_at IS NULL"`
+	Barcode   string       `json:"barcode" gorm:"column:barcode;index:idx_product_variants_barcode,unique,where:barcode <> '' AND deleted_at IS NULL"`
+	Options   OptionValues `json:"options" gorm:"column:options;type:jsonb;not null"`
+	Price     *int         `json:"price" gorm:"column:price"`
+	Quantity  int          `json:"quantity" gorm:"column:quantity;default:0;not null"`
+	gorm.Model
+}
+
+type VariantDetail struct {
+	ProductVariant
+	Label          string          `json:"label"`
+	EffectivePrice int             `json:"effective_price"`
+	Stock          []LocationStock `json:"stock"`
+}
+
+func (v ProductVariant) EffectivePrice(product *Product) int {
+	if v.Price != nil {
+		return *v.Price
+	}
+
+	return product.Price
+}
+
+func productOptions(tx *gorm.DB, productID uuid.UUID) ([]ProductOption, error) {
+	var options []ProductOption
+
+	if result := tx.Where("product_id = ?", productID).Order("position asc").Find(&options); result.Error != nil {
+		return nil, result.Error
+	}
+
+	return options, nil
+}
+
+func productHasVariants(tx *gorm.DB, productID uuid.UUID) (bool, error) {
+	var count int64
+
+	if result := tx.Model(&ProductVariant{}).Where("product_id = ?", productID).Count(&count); result.Error != nil {
+		return false, result.Error
+	}
+
+	return count > 0, nil
+}
+
+// checkVariant makes sure a line names a variant exactly when the product has
+// variants, and that the variant belongs to that product.
+func checkVariant(tx *gorm.DB, productID uuid.UUID, variantID uuid.UUID) error {
+	if variantID != uuid.Nil {
+		result := tx.Where("id = ? AND product_id = ?", variantID, productID).First(&ProductVariant{})
+		if result.Error != nil {
+			return fmt.Errorf("variant %v does not belong to product %v", variantID, productID)
+		}
+
+		return nil
+	}
+
+	hasVariants, err := productHasVariants(tx, productID)
+	if err != nil {
+		return err
+	}
+
+	if hasVariants {
+		return fmt.Errorf("product %v has variants, specify a variant_id", productID)
+	}
+
+	return nil
+}
+
+func (p ProductService) SetProductOptions(id uuid.UUID, params []data.ProductOptionParams) ([]ProductOption, error) {
+	if _, err := p.GetProductById(id); err != nil {
+		return nil, err
+	}
+
+	var options []ProductOption
+	seen := map[string]bool{}
+
+	for i, param := range params {
+		name := strings.TrimSpace(param.Name)
+		if name == "" || len(param.Values) == 0 {
+			return nil, errors.New("every option needs a name and at least one value")
+		}
+
+		if seen[name] {
+			return nil, fmt.Errorf("option %v is listed twice", name)
+		}
+		seen[name] = true
+
+		options = append(options, ProductOption{
+			ID:        uuid.New(),
+			ProductID: id,
+			Name:      name,
+			Values:    StringList(param.Values),
+			Position:  i,
+		})
+	}
+
+	err := p.DB.Transaction(func(tx *gorm.DB) error {
+		hasVariants, err := productHasVariants(tx, id)
+		if err != nil {
+			return err
+		}
+
+		if hasVariants {
+			return errors.New("remove the existing variants before changing options")
+		}
+
+		if result := tx.Unscoped().Where("product_id = ?", id).Delete(&ProductOption{}); result.Error != nil {
+			return result.Error
+		}
+
+		if len(options) == 0 {
+			return nil
+		}
+
+		return tx.Create(&options).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return options, nil
+}
+
+// validateVariantOptions checks that a variant picks exactly one allowed value
+// for every option dimension and that no other variant has the same combination.
+func validateVariantOptions(tx *gorm.DB, productID uuid.UUID, variantID uuid.UUID, values OptionValues, options []ProductOption) error {
+	if len(options) == 0 {
+		return errors.New("define product options before adding variants")
+	}
+
+	if len(values) != len(options) {
+		return errors.New("variant must set a value for every product option")
+	}
+
+	for _, option := range options {
+		value, ok := values[option.Name]
+		if !ok {
+			return fmt.Errorf("variant is missing a value for %v", option.Name)
+		}
+
+		allowed := false
+		for _, candidate := range option.Values {
+			if candidate == value {
+				allowed = true
+			}
+		}
+
+		if !allowed {
+			return fmt.Errorf("%v is not a valid value for %v", value, option.Name)
+		}
+	}
+
+	var siblings []ProductVariant
+	if result := tx.Where("product_id = ? AND id <> ?", productID, variantID).Find(&siblings); result.Error != nil {
+		return result.Error
+	}
+
+	for _, sibling := range siblings {
+		if sibling.Options.Label(options) == values.Label(options) {
+			return fmt.Errorf("a variant for %v already exists", values.Label(options))
+		}
+	}
+
+	return nil
+}
+
+func (p ProductService) CreateVariant(productID uuid.UUID, param data.VariantParams, userId uuid.UUID) (*ProductVariant, error) {
+	if param.Price != nil && *param.Price < 0 {
+		return nil, errors.New("price cannot be negative")
+	}
+
+	if param.Quantity < 0 {
+		return nil, errors.New("quantity cannot be negative")
+	}
+
+	if err := validateCodes(param.SKU, param.Barcode); err != nil {
+		return nil, err
+	}
+
+	variant := ProductVariant{
+		ID:        uuid.New(),
+		ProductID: productID,
+		SKU:       param.SKU,
+		Barcode:   param.Barcode,
+		Options:   OptionValues(param.Options),
+		Price:     param.Price,
+	}
+
+	err := stockTransaction(p.DB, func(tx *gorm.DB) error {
+		level, err := applyStockChange(tx, stockChange{ProductID: productID})
+		if err != nil {
+			return err
+		}
+
+		hasVariants, err := productHasVariants(tx, productID)
+		if err != nil {
+			return err
+		}
+
+		if !hasVariants && level.Product.Quantity != 0 {
+			return errors.New("move the product's existing stock to zero before adding variants")
+		}
+
+		options, err := productOptions(tx, productID)
+		if err != nil {
+			return err
+		}
+
+		if err := validateVariantOptions(tx, productID, variant.ID, variant.Options, options); err != nil {
+			return err
+		}
+
+		if err := ensureUniqueCodes(tx, uuid.Nil, variant.ID, variant.SKU, variant.Barcode); err != nil {
+			return err
+		}
+
+		if result := tx.Create(&variant); result.Error != nil {
+			return result.Error
+		}
+
+		updated, err := applyStockChange(tx, stockChange{
+			ProductID:   productID,
+			VariantID:   variant.ID,
+			LocationID:  param.LocationID,
+			Delta:       param.Quantity,
+			Reason:      MovementRestock,
+			ReferenceID: variant.ID,
+			UserID:      userId,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		variant.Quantity = updated.Variant.Quantity
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &variant, nil
+}
+
+func (p ProductService) GetVariant(productID uuid.UUID, variantID uuid.UUID) (*ProductVariant, error) {
+	var variant ProductVariant
+
+	if result := p.DB.Where("id = ? AND product_id = ?", variantID, productID).First(&variant); result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &variant, nil
+}
+
+func (p ProductService) UpdateVariant(productID uuid.UUID, variantID uuid.UUID, param data.VariantParams) error {
+	if param.Price != nil && *param.Price < 0 {
+		return errors.New("price cannot be negative")
+	}
+
+	if err := validateCodes(param.SKU, param.Barcode); err != nil {
+		return err
+	}
+
+	variant, err := p.GetVariant(productID, variantID)
+	if err != nil {
+		return err
+	}
+
+	variant.SKU = param.SKU
+	variant.Barcode = param.Barcode
+	variant.Options = OptionValues(param.Options)
+	variant.Price = param.Price
+
+	return p.DB.Transaction(func(tx *gorm.DB) error {
+		options, err := productOptions(tx, productID)
+		if err != nil {
+			return err
+		}
+
+		if err := validateVariantOptions(tx, productID, variantID, variant.Options, options); err != nil {
+			return err
+		}
+
+		if err := ensureUniqueCodes(tx, uuid.Nil, variantID, variant.SKU, variant.Barcode); err != nil {
+			return err
+		}
+
+		return tx.Omit("quantity").Save(variant).Error
+	})
+}
+
+func (p ProductService) DeleteVariant(productID uuid.UUID, variantID uuid.UUID) error {
+	variant, err := p.GetVariant(productID, variantID)
+	if err != nil {
+		return err
+	}
+
+	if variant.Quantity != 0 {
+		return errors.New("variant still holds stock")
+	}
+
+	if result := p.DB.Where("id = ?", variantID).Delete(&ProductVariant{}); result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
+
+func (p ProductService) getVariantDetails(product *Product, options []ProductOption) ([]VariantDetail, error) {
+	var variants []ProductVariant
+
+	if result := p.DB.Where("product_id = ?", product.ID).Order("created_at asc").Find(&variants); result.Error != nil {
+		return nil, result.Error
+	}
+
+	var stock []struct {
+		VariantID uuid.UUID
+		LocationStock
+	}
+
+	result := p.DB.Model(&ProductStock{}).
+		Select("product_stocks.variant_id, product_stocks.location_id, locations.name AS location_name, product_stocks.quantity").
+		Joins("JOIN locations ON locations.id = product_stocks.location_id").
+		Where("product_stocks.product_id = ? AND product_stocks.variant_id <> ?", product.ID, uuid.Nil).
+		Order("locations.name asc").
+		Scan(&stock)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	details := make([]VariantDetail, 0, len(variants))
+	for _, variant := range variants {
+		detail := VariantDetail{
+			ProductVariant: variant,
+			Label:          variant.Options.Label(options),
+			EffectivePrice: variant.EffectivePrice(product),
+			Stock:          []LocationStock{},
+		}
+
+		for _, level := range stock {
+			if level.VariantID == variant.ID {
+				detail.Stock = append(detail.Stock, level.LocationStock)
+			}
+		}
+
+		details = append(details, detail)
+	}
+
+	return details, nil
+}
+
+// MigrateVariants drops the product/location unique index that predates
+// variants; it is replaced by one that also covers variant_id.
+func MigrateVariants(db *gorm.DB) error {
+	if db.Migrator().HasIndex(&ProductStock{}, "idx_product_location") {
+		return db.Migrator().DropIndex(&ProductStock{}, "idx_product_location")
+	}
+
+	return nil
+}
@@ -0,0 +1,21 @@
+package response
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/gin-gonic/gin"
+	"github.com/loyalsfc/investrite/spreadsheet"
+)
+
+// Export streams src as a file download. Headers are already sent once rows
+// start flowing, so a failure part way through can only be logged.
+func Export(ctx *gin.Context, name string, format string, src spreadsheet.RowSource) {
+	ctx.Header("Content-Type", spreadsheet.ContentType(format))
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%v.%v", name, format))
+	ctx.Status(200)
+
+	if err := spreadsheet.Stream(ctx.Writer, format, src); err != nil {
+		log.Printf("%v export failed: %v", name, err)
+	}
+}
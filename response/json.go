@@ -25,3 +25,12 @@ func PermissionError(ctx *gin.Context) {
 		"payload": nil,
 	})
 }
+
+func SuccessWithMeta(ctx *gin.Context, message string, payload interface{}, meta interface{}) {
+	ctx.JSON(200, gin.H{
+		"status":  "success",
+		"message": message,
+		"payload": payload,
+		"meta":    meta,
+	})
+}
@@ -5,17 +5,26 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/loyalsfc/investrite/controller/audit"
 	"github.com/loyalsfc/investrite/controller/auth"
 	"github.com/loyalsfc/investrite/controller/categories"
 	"github.com/loyalsfc/investrite/controller/items"
+	"github.com/loyalsfc/investrite/controller/locations"
 	"github.com/loyalsfc/investrite/controller/orders"
+	"github.com/loyalsfc/investrite/controller/reports"
+	"github.com/loyalsfc/investrite/controller/roles"
+	"github.com/loyalsfc/investrite/controller/stocktake"
+	"github.com/loyalsfc/investrite/controller/suppliers"
+	"github.com/loyalsfc/investrite/controller/transfers"
 	"github.com/loyalsfc/investrite/controller/user"
+	"github.com/loyalsfc/investrite/mailer"
 	"github.com/loyalsfc/investrite/middleware"
 	"github.com/loyalsfc/investrite/models"
+	"github.com/loyalsfc/investrite/utils"
 	"gorm.io/gorm"
 )
 
-func InitRoutes(db *gorm.DB) *gin.Engine {
+func InitRoutes(db *gorm.DB, mail mailer.Sender) *gin.Engine {
 	r := gin.Default()
 
 	r.GET("/ping", func(c *gin.Context) {
@@ -33,21 +42,54 @@ func InitRoutes(db *gorm.DB) *gin.Engine {
 	}
 
 	authHandler := &auth.AuthHandler{
-		UserService: *userService,
+		UserService:  *userService,
+		TokenService: models.TokenService{DB: db},
+		Mailer:       mail,
 	}
 
+	r.GET("/.well-known/jwks.json", authHandler.JWKS)
+
 	authRoutes := r.Group("/auth")
-	authRoutes.POST("/register", authHandler.NewUser)
+	authRoutes.POST("/register", middlware.AuditAccount("register", authHandler.NewUser))
 	authRoutes.POST("/signin", authHandler.Signin)
+	authRoutes.POST("/refresh", authHandler.Refresh)
+	authRoutes.POST("/logout", middlware.MiddlewareAuth(authHandler.Logout))
+	authRoutes.GET("/verify-email", middlware.AuditAccount("verify_email", authHandler.VerifyEmail))
+	authRoutes.POST("/forgot-password", authHandler.ForgotPassword)
+	authRoutes.POST("/reset-password", middlware.AuditAccount("reset_password", authHandler.ResetPassword))
+	authRoutes.POST("/accept-invite", middlware.AuditAccount("accept_invite", authHandler.AcceptInvite))
+
+	roleService := models.RoleService{DB: db}
 
 	userHandler := &user.UserHandler{
 		UserService: *userService,
+		RoleService: roleService,
+		Mailer:      mail,
 	}
+	userLoader := middleware.Loader(userService.GetUserById)
+	invitationLoader := middleware.Loader(userService.GetInvitation)
 
 	userRoutes := r.Group("/user")
-	userRoutes.GET("/all", middlware.MiddlewareAuth(userHandler.GetAllUsers))
-	userRoutes.POST("/update-role", middlware.MiddlewareAuth(userHandler.UpdateRole))
-	userRoutes.DELETE("/:userID", middlware.MiddlewareAuth(userHandler.DeleteUser))
+	userRoutes.GET("/all", middlware.MiddlewareAuth(userHandler.GetAllUsers, utils.PermUserRead))
+	userRoutes.GET("/export", middlware.MiddlewareAuth(userHandler.ExportUsers, utils.PermUserExport))
+	userRoutes.POST("/update-role", middlware.MiddlewareAuth(middlware.Audit("user", "update_role", "", userLoader, userHandler.UpdateRole), utils.PermUserManage))
+	userRoutes.POST("/invite", middlware.MiddlewareAuth(middlware.Audit("invitation", "create", "", nil, userHandler.InviteUser), utils.PermUserManage))
+	userRoutes.GET("/invitations", middlware.MiddlewareAuth(userHandler.GetInvitations, utils.PermUserManage))
+	userRoutes.DELETE("/invitations/:invitationID", middlware.MiddlewareAuth(middlware.Audit("invitation", "revoke", "invitationID", invitationLoader, userHandler.RevokeInvitation), utils.PermUserManage))
+	userRoutes.DELETE("/:userID", middlware.MiddlewareAuth(middlware.Audit("user", "delete", "userID", userLoader, userHandler.DeleteUser)))
+
+	roleHandler := roles.RoleHandler{
+		RoleService: roleService,
+	}
+
+	roleLoader := middleware.Loader(roleService.GetRole)
+
+	roleRoutes := r.Group("/role")
+	roleRoutes.GET("/", middlware.MiddlewareAuth(roleHandler.GetRoles, utils.PermRoleManage))
+	roleRoutes.GET("/permissions", middlware.MiddlewareAuth(roleHandler.GetPermissions, utils.PermRoleManage))
+	roleRoutes.POST("/new", middlware.MiddlewareAuth(middlware.Audit("role", "create", "", nil, roleHandler.NewRole), utils.PermRoleManage))
+	roleRoutes.PUT("/:roleID", middlware.MiddlewareAuth(middlware.Audit("role", "update", "roleID", roleLoader, roleHandler.UpdateRole), utils.PermRoleManage))
+	roleRoutes.DELETE("/:roleID", middlware.MiddlewareAuth(middlware.Audit("role", "delete", "roleID", roleLoader, roleHandler.DeleteRole), utils.PermRoleManage))
 
 	categoryModel := &models.CategoryModel{
 		DB: db,
@@ -56,13 +98,14 @@ func InitRoutes(db *gorm.DB) *gin.Engine {
 	categoryHandler := &categories.CategoryHandler{
 		CategoryService: *categoryModel,
 	}
+	categoryLoader := middleware.Loader(categoryModel.FindCategoryById)
 
 	categoryRoute := r.Group("/category")
-	categoryRoute.POST("/new-category", middlware.MiddlewareAuth(categoryHandler.NewCategory))
-	categoryRoute.GET("/:id", middlware.MiddlewareAuth(categoryHandler.GetCategory))
-	categoryRoute.PUT("/:id", middlware.MiddlewareAuth(categoryHandler.EditCategory))
-	categoryRoute.DELETE("/:id", middlware.MiddlewareAuth(categoryHandler.DeleteCategory))
-	categoryRoute.GET("/", middlware.MiddlewareAuth(categoryHandler.GetCategories))
+	categoryRoute.POST("/new-category", middlware.MiddlewareAuth(middlware.Audit("category", "create", "", nil, categoryHandler.NewCategory), utils.PermCategoryWrite))
+	categoryRoute.GET("/:id", middlware.MiddlewareAuth(categoryHandler.GetCategory, utils.PermCategoryRead))
+	categoryRoute.PUT("/:id", middlware.MiddlewareAuth(middlware.Audit("category", "update", "id", categoryLoader, categoryHandler.EditCategory), utils.PermCategoryWrite))
+	categoryRoute.DELETE("/:id", middlware.MiddlewareAuth(middlware.Audit("category", "delete", "id", categoryLoader, categoryHandler.DeleteCategory), utils.PermCategoryWrite))
+	categoryRoute.GET("/", middlware.MiddlewareAuth(categoryHandler.GetCategories, utils.PermCategoryRead))
 
 	productService := &models.ProductService{
 		DB: db,
@@ -70,16 +113,33 @@ func InitRoutes(db *gorm.DB) *gin.Engine {
 
 	productHandler := &items.ProductHandler{
 		ProductService: *productService,
+		AlertService:   models.AlertService{DB: db},
 	}
+	productLoader := middleware.Loader(productService.GetProductById)
+	productDetailLoader := middleware.Loader(productService.GetProductDetail)
 
 	productRoute := r.Group("/product")
-	productRoute.POST("/new-product", middlware.MiddlewareAuth(productHandler.NewProduct))
-	productRoute.GET("/", middlware.MiddlewareAuth(productHandler.GetProducts))
-	productRoute.GET("/:productID", middlware.MiddlewareAuth(productHandler.GetProduct))
-	productRoute.PUT("/:productID", middlware.MiddlewareAuth(productHandler.UpdateProduct))
-	productRoute.DELETE("/:productID", middlware.MiddlewareAuth(productHandler.DeleteProduct))
-	productRoute.GET("/increase-quantity/:productID", middlware.MiddlewareAuth(productHandler.IncreaseProductQuantity))
-	productRoute.GET("/decrease-quantity/:productID", middlware.MiddlewareAuth(productHandler.DecreaseProductQuantity))
+	productRoute.POST("/new-product", middlware.MiddlewareAuth(middlware.Audit("product", "create", "", nil, productHandler.NewProduct), utils.PermProductWrite))
+	productRoute.POST("/import", middlware.MiddlewareAuth(middlware.Audit("product", "import", "", nil, productHandler.ImportProducts), utils.PermProductWrite))
+	productRoute.POST("/adjust", middlware.MiddlewareAuth(middlware.Audit("product", "adjust", "", nil, productHandler.AdjustProductQuantities), utils.PermStockAdjust))
+	productRoute.GET("/", middlware.MiddlewareAuth(productHandler.GetProducts, utils.PermProductRead))
+	productRoute.GET("/export", middlware.MiddlewareAuth(productHandler.ExportProducts, utils.PermProductRead))
+	productRoute.GET("/movements/export", middlware.MiddlewareAuth(productHandler.ExportMovements, utils.PermStockLedger))
+	productRoute.GET("/low-stock", middlware.MiddlewareAuth(productHandler.GetLowStockProducts, utils.PermStockRead))
+	productRoute.GET("/search", middlware.MiddlewareAuth(productHandler.SearchProducts, utils.PermProductRead))
+	productRoute.GET("/lookup", middlware.MiddlewareAuth(productHandler.LookupProduct, utils.PermProductRead))
+	productRoute.POST("/labels", middlware.MiddlewareAuth(productHandler.PrintLabels, utils.PermProductRead))
+	productRoute.GET("/alerts", middlware.MiddlewareAuth(productHandler.GetStockAlerts, utils.PermStockRead))
+	productRoute.GET("/:productID", middlware.MiddlewareAuth(productHandler.GetProduct, utils.PermProductRead))
+	productRoute.PUT("/:productID", middlware.MiddlewareAuth(middlware.Audit("product", "update", "productID", productLoader, productHandler.UpdateProduct), utils.PermProductWrite))
+	productRoute.DELETE("/:productID", middlware.MiddlewareAuth(middlware.Audit("product", "delete", "productID", productLoader, productHandler.DeleteProduct), utils.PermProductDelete))
+	productRoute.GET("/:productID/movements", middlware.MiddlewareAuth(productHandler.GetProductMovements, utils.PermStockLedger))
+	productRoute.PUT("/:productID/options", middlware.MiddlewareAuth(middlware.Audit("product", "set_options", "productID", productDetailLoader, productHandler.SetProductOptions), utils.PermProductWrite))
+	productRoute.POST("/:productID/variants", middlware.MiddlewareAuth(middlware.Audit("product", "create_variant", "productID", productDetailLoader, productHandler.CreateVariant), utils.PermProductWrite))
+	productRoute.PUT("/:productID/variants/:variantID", middlware.MiddlewareAuth(middlware.Audit("product", "update_variant", "productID", productDetailLoader, productHandler.UpdateVariant), utils.PermProductWrite))
+	productRoute.DELETE("/:productID/variants/:variantID", middlware.MiddlewareAuth(middlware.Audit("product", "delete_variant", "productID", productDetailLoader, productHandler.DeleteVariant), utils.PermProductWrite))
+	productRoute.GET("/increase-quantity/:productID", middlware.MiddlewareAuth(middlware.Audit("product", "increase_quantity", "productID", productLoader, productHandler.IncreaseProductQuantity), utils.PermStockAdjust))
+	productRoute.GET("/decrease-quantity/:productID", middlware.MiddlewareAuth(middlware.Audit("product", "decrease_quantity", "productID", productLoader, productHandler.DecreaseProductQuantity), utils.PermStockAdjust))
 
 	orderService := models.OrderService{
 		DB: db,
@@ -88,12 +148,93 @@ func InitRoutes(db *gorm.DB) *gin.Engine {
 	orderHandler := orders.OrderHandler{
 		OrderService: orderService,
 	}
+	orderLoader := middleware.Loader(orderService.FindOrder)
 
 	orderRoutes := r.Group("/order")
-	orderRoutes.POST("/new", middlware.MiddlewareAuth(orderHandler.NewOrder))
-	orderRoutes.GET("/", middlware.MiddlewareAuth(orderHandler.GetOrders))
-	orderRoutes.GET("/:orderId", middlware.MiddlewareAuth(orderHandler.GetOrder))
-	orderRoutes.DELETE("/:orderId", middlware.MiddlewareAuth(orderHandler.DeleteOrder))
+	orderRoutes.POST("/new", middlware.MiddlewareAuth(middlware.Audit("order", "create", "", nil, orderHandler.NewOrder), utils.PermOrderCreate))
+	orderRoutes.GET("/", middlware.MiddlewareAuth(orderHandler.GetOrders, utils.PermOrderRead))
+	orderRoutes.GET("/export", middlware.MiddlewareAuth(orderHandler.ExportOrders, utils.PermOrderRead))
+	orderRoutes.GET("/:orderId", middlware.MiddlewareAuth(orderHandler.GetOrder, utils.PermOrderRead))
+	orderRoutes.DELETE("/:orderId", middlware.MiddlewareAuth(middlware.Audit("order", "delete", "orderId", orderLoader, orderHandler.DeleteOrder), utils.PermOrderDelete))
+	orderRoutes.POST("/:orderId/refund", middlware.MiddlewareAuth(middlware.Audit("order", "refund", "orderId", orderLoader, orderHandler.RefundOrder), utils.PermOrderRefund))
+
+	supplierHandler := suppliers.SupplierHandler{
+		SupplierService:      models.SupplierService{DB: db},
+		PurchaseOrderService: models.PurchaseOrderService{DB: db},
+	}
+	supplierLoader := middleware.Loader(supplierHandler.SupplierService.GetSupplierById)
+	purchaseOrderLoader := middleware.Loader(supplierHandler.PurchaseOrderService.GetPurchaseOrder)
+
+	supplierRoutes := r.Group("/supplier")
+	supplierRoutes.POST("/new", middlware.MiddlewareAuth(middlware.Audit("supplier", "create", "", nil, supplierHandler.NewSupplier), utils.PermSupplierWrite))
+	supplierRoutes.GET("/", middlware.MiddlewareAuth(supplierHandler.GetSuppliers, utils.PermSupplierRead))
+	supplierRoutes.GET("/:supplierID", middlware.MiddlewareAuth(supplierHandler.GetSupplier, utils.PermSupplierRead))
+	supplierRoutes.PUT("/:supplierID", middlware.MiddlewareAuth(middlware.Audit("supplier", "update", "supplierID", supplierLoader, supplierHandler.UpdateSupplier), utils.PermSupplierWrite))
+	supplierRoutes.DELETE("/:supplierID", middlware.MiddlewareAuth(middlware.Audit("supplier", "delete", "supplierID", supplierLoader, supplierHandler.DeleteSupplier), utils.PermSupplierWrite))
+
+	purchaseOrderRoutes := r.Group("/purchase-order")
+	purchaseOrderRoutes.POST("/new", middlware.MiddlewareAuth(middlware.Audit("purchase_order", "create", "", nil, supplierHandler.NewPurchaseOrder), utils.PermPurchaseWrite))
+	purchaseOrderRoutes.GET("/", middlware.MiddlewareAuth(supplierHandler.GetPurchaseOrders, utils.PermPurchaseRead))
+	purchaseOrderRoutes.GET("/:purchaseOrderID", middlware.MiddlewareAuth(supplierHandler.GetPurchaseOrder, utils.PermPurchaseRead))
+	purchaseOrderRoutes.PUT("/:purchaseOrderID", middlware.MiddlewareAuth(middlware.Audit("purchase_order", "update", "purchaseOrderID", purchaseOrderLoader, supplierHandler.UpdatePurchaseOrder), utils.PermPurchaseWrite))
+	purchaseOrderRoutes.POST("/:purchaseOrderID/submit", middlware.MiddlewareAuth(middlware.Audit("purchase_order", "submit", "purchaseOrderID", purchaseOrderLoader, supplierHandler.SubmitPurchaseOrder), utils.PermPurchaseWrite))
+	purchaseOrderRoutes.POST("/:purchaseOrderID/cancel", middlware.MiddlewareAuth(middlware.Audit("purchase_order", "cancel", "purchaseOrderID", purchaseOrderLoader, supplierHandler.CancelPurchaseOrder), utils.PermPurchaseWrite))
+	purchaseOrderRoutes.POST("/:purchaseOrderID/receive", middlware.MiddlewareAuth(middlware.Audit("purchase_order", "receive", "purchaseOrderID", purchaseOrderLoader, supplierHandler.ReceivePurchaseOrder), utils.PermPurchaseReceive))
+
+	stocktakeHandler := stocktake.StocktakeHandler{
+		StocktakeService: models.StocktakeService{DB: db},
+	}
+	stocktakeLoader := middleware.Loader(stocktakeHandler.StocktakeService.GetStocktake)
+
+	stocktakeRoutes := r.Group("/stocktake")
+	stocktakeRoutes.POST("/new", middlware.MiddlewareAuth(middlware.Audit("stocktake", "create", "", nil, stocktakeHandler.OpenStocktake), utils.PermStocktakeManage))
+	stocktakeRoutes.GET("/", middlware.MiddlewareAuth(stocktakeHandler.GetStocktakes, utils.PermStocktakeRead))
+	stocktakeRoutes.GET("/:stocktakeID", middlware.MiddlewareAuth(stocktakeHandler.GetStocktake, utils.PermStocktakeRead))
+	stocktakeRoutes.POST("/:stocktakeID/counts", middlware.MiddlewareAuth(middlware.Audit("stocktake", "submit_counts", "stocktakeID", stocktakeLoader, stocktakeHandler.SubmitCounts), utils.PermStocktakeCount))
+	stocktakeRoutes.POST("/:stocktakeID/close", middlware.MiddlewareAuth(middlware.Audit("stocktake", "close", "stocktakeID", stocktakeLoader, stocktakeHandler.CloseStocktake), utils.PermStocktakeManage))
+	stocktakeRoutes.POST("/:stocktakeID/approve", middlware.MiddlewareAuth(middlware.Audit("stocktake", "approve", "stocktakeID", stocktakeLoader, stocktakeHandler.ApproveStocktake), utils.PermStocktakeManage))
+	stocktakeRoutes.POST("/:stocktakeID/cancel", middlware.MiddlewareAuth(middlware.Audit("stocktake", "cancel", "stocktakeID", stocktakeLoader, stocktakeHandler.CancelStocktake), utils.PermStocktakeManage))
+
+	locationHandler := locations.LocationHandler{
+		LocationService: models.LocationService{DB: db},
+	}
+	locationLoader := middleware.Loader(locationHandler.LocationService.GetLocationById)
+
+	locationRoutes := r.Group("/location")
+	locationRoutes.POST("/new", middlware.MiddlewareAuth(middlware.Audit("location", "create", "", nil, locationHandler.NewLocation), utils.PermLocationManage))
+	locationRoutes.GET("/", middlware.MiddlewareAuth(locationHandler.GetLocations, utils.PermLocationRead))
+	locationRoutes.GET("/:locationID", middlware.MiddlewareAuth(locationHandler.GetLocation, utils.PermLocationRead))
+	locationRoutes.PUT("/:locationID", middlware.MiddlewareAuth(middlware.Audit("location", "update", "locationID", locationLoader, locationHandler.UpdateLocation), utils.PermLocationManage))
+	locationRoutes.DELETE("/:locationID", middlware.MiddlewareAuth(middlware.Audit("location", "delete", "locationID", locationLoader, locationHandler.DeleteLocation), utils.PermLocationManage))
+	locationRoutes.POST("/:locationID/default", middlware.MiddlewareAuth(middlware.Audit("location", "set_default", "locationID", locationLoader, locationHandler.SetDefaultLocation), utils.PermLocationManage))
+	locationRoutes.GET("/:locationID/stock", middlware.MiddlewareAuth(locationHandler.GetLocationStock, utils.PermLocationRead))
+
+	transferHandler := transfers.TransferHandler{
+		TransferService: models.TransferService{DB: db},
+	}
+	transferLoader := middleware.Loader(transferHandler.TransferService.GetTransfer)
+
+	transferRoutes := r.Group("/transfer")
+	transferRoutes.POST("/new", middlware.MiddlewareAuth(middlware.Audit("transfer", "create", "", nil, transferHandler.RequestTransfer), utils.PermTransferRequest))
+	transferRoutes.GET("/", middlware.MiddlewareAuth(transferHandler.GetTransfers, utils.PermTransferRead))
+	transferRoutes.GET("/:transferID", middlware.MiddlewareAuth(transferHandler.GetTransfer, utils.PermTransferRead))
+	transferRoutes.POST("/:transferID/dispatch", middlware.MiddlewareAuth(middlware.Audit("transfer", "dispatch", "transferID", transferLoader, transferHandler.DispatchTransfer), utils.PermTransferManage))
+	transferRoutes.POST("/:transferID/receive", middlware.MiddlewareAuth(middlware.Audit("transfer", "receive", "transferID", transferLoader, transferHandler.ReceiveTransfer), utils.PermTransferReceive))
+	transferRoutes.POST("/:transferID/cancel", middlware.MiddlewareAuth(middlware.Audit("transfer", "cancel", "transferID", transferLoader, transferHandler.CancelTransfer), utils.PermTransferManage))
+
+	reportHandler := reports.ReportHandler{
+		ReportService: models.ReportService{DB: db},
+	}
+
+	reportRoutes := r.Group("/report")
+	reportRoutes.GET("/sales", middlware.MiddlewareAuth(reportHandler.GetSalesReport, utils.PermReportView))
+	reportRoutes.GET("/inventory-valuation", middlware.MiddlewareAuth(reportHandler.GetInventoryValuation, utils.PermReportView))
+
+	auditHandler := audit.AuditHandler{
+		AuditService: models.AuditService{DB: db},
+	}
+
+	r.GET("/audit", middlware.MiddlewareAuth(auditHandler.GetAuditEvents, utils.PermAuditView))
 
 	return r
 }
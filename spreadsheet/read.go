@@ -0,0 +1,314 @@
+package spreadsheet
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// maxColumns is the widest sheet a spreadsheet program can produce (XFD).
+const maxColumns = 16384
+
+// maxEntrySize bounds how much any one file inside an xlsx may decompress to.
+const maxEntrySize = 32 << 20
+
+// Read parses a CSV or XLSX file, chosen by its extension, into rows of
+// cells. Row i of the result is line i+1 of the file, so callers can report
+// errors against the numbers users see in their spreadsheet program. Files
+// with more than maxRows rows are rejected.
+func Read(filename string, r io.Reader, maxRows int) ([][]string, error) {
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".csv":
+		return ReadCSV(r, maxRows)
+	case ".xlsx":
+		content, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		return ReadXLSX(bytes.NewReader(content), int64(len(content)), maxRows)
+	default:
+		return nil, errors.New("file must be a .csv or .xlsx spreadsheet")
+	}
+}
+
+func ReadCSV(r io.Reader, maxRows int) ([][]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var rows [][]string
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if len(rows) == maxRows {
+			return nil, fmt.Errorf("sheet has more than %v rows", maxRows)
+		}
+		rows = append(rows, row)
+	}
+
+	//Spreadsheet programs often save CSV with a byte order mark
+	if len(rows) > 0 && len(rows[0]) > 0 {
+		rows[0][0] = strings.TrimPrefix(rows[0][0], "\ufeff")
+	}
+
+	return rows, nil
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxText struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	if len(t.Runs) == 0 {
+		return t.Text
+	}
+
+	var b strings.Builder
+	for _, run := range t.Runs {
+		b.WriteString(run.Text)
+	}
+	return b.String()
+}
+
+type xlsxRow struct {
+	Index int `xml:"r,attr"`
+	Cells []struct {
+		Ref    string   `xml:"r,attr"`
+		Type   string   `xml:"t,attr"`
+		Value  string   `xml:"v"`
+		Inline xlsxText `xml:"is"`
+	} `xml:"c"`
+}
+
+// ReadXLSX returns the cells of the first worksheet. Formulas are read as
+// their cached values and styles are ignored. Row and cell references come
+// from the file, so they are checked before anything is allocated for them.
+func ReadXLSX(r io.ReaderAt, size int64, maxRows int) ([][]string, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, errors.New("file is not a valid xlsx spreadsheet")
+	}
+
+	files := map[string]*zip.File{}
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+
+	var workbook xlsxWorkbook
+	if err := decodeXML(files, "xl/workbook.xml", &workbook); err != nil {
+		return nil, err
+	}
+
+	var rels xlsxRelationships
+	if err := decodeXML(files, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, err
+	}
+
+	if len(workbook.Sheets) == 0 {
+		return nil, errors.New("workbook has no sheets")
+	}
+
+	sheetPath := ""
+	for _, rel := range rels.Relationships {
+		if rel.ID == workbook.Sheets[0].RID {
+			sheetPath = rel.Target
+		}
+	}
+
+	if strings.HasPrefix(sheetPath, "/") {
+		sheetPath = strings.TrimPrefix(sheetPath, "/")
+	} else {
+		sheetPath = path.Join("xl", sheetPath)
+	}
+
+	var shared []string
+	if _, ok := files["xl/sharedStrings.xml"]; ok {
+		var sst struct {
+			Items []xlsxText `xml:"si"`
+		}
+		if err := decodeXML(files, "xl/sharedStrings.xml", &sst); err != nil {
+			return nil, err
+		}
+		for _, item := range sst.Items {
+			shared = append(shared, item.String())
+		}
+	}
+
+	//Rows are decoded one at a time so a sheet cannot hold more than maxRows
+	var sheetRows []xlsxRow
+	err = readXML(files, sheetPath, func(decoder *xml.Decoder) error {
+		for {
+			token, err := decoder.Token()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			start, ok := token.(xml.StartElement)
+			if !ok || start.Name.Local != "row" {
+				continue
+			}
+
+			if len(sheetRows) == maxRows {
+				return fmt.Errorf("sheet has more than %v rows", maxRows)
+			}
+
+			var row xlsxRow
+			if err := decoder.DecodeElement(&row, &start); err != nil {
+				return err
+			}
+			sheetRows = append(sheetRows, row)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var rows [][]string
+	for i, row := range sheetRows {
+		index := row.Index
+		if index == 0 {
+			index = i + 1
+		}
+
+		if index < 0 {
+			return nil, fmt.Errorf("row %v has an invalid reference", index)
+		}
+
+		if index > maxRows {
+			return nil, fmt.Errorf("sheet has more than %v rows", maxRows)
+		}
+
+		for len(rows) < index {
+			rows = append(rows, nil)
+		}
+
+		var cells []string
+		for j, cell := range row.Cells {
+			column := j
+			if cell.Ref != "" {
+				column = columnIndex(cell.Ref)
+			}
+
+			if column < 0 || column >= maxColumns {
+				return nil, fmt.Errorf("cell %q has an invalid reference", cell.Ref)
+			}
+
+			value := cell.Value
+			switch cell.Type {
+			case "s":
+				n, err := strconv.Atoi(value)
+				if err != nil || n < 0 || n >= len(shared) {
+					return nil, fmt.Errorf("cell %v refers to a missing shared string", cell.Ref)
+				}
+				value = shared[n]
+			case "inlineStr":
+				value = cell.Inline.String()
+			case "b":
+				value = map[string]string{"1": "true", "0": "false"}[value]
+			case "", "n":
+				//Long numbers such as barcodes may be stored in exponent form
+				if strings.ContainsAny(value, "eE") {
+					if f, err := strconv.ParseFloat(value, 64); err == nil {
+						value = strconv.FormatFloat(f, 'f', -1, 64)
+					}
+				}
+			}
+
+			for len(cells) <= column {
+				cells = append(cells, "")
+			}
+			cells[column] = value
+		}
+
+		rows[index-1] = cells
+	}
+
+	return rows, nil
+}
+
+func decodeXML(files map[string]*zip.File, name string, dest interface{}) error {
+	return readXML(files, name, func(decoder *xml.Decoder) error {
+		return decoder.Decode(dest)
+	})
+}
+
+// readXML hands read a decoder over one entry of the archive. Entries are
+// capped at maxEntrySize once decompressed, whatever size the archive claims,
+// so a small upload cannot expand into gigabytes.
+func readXML(files map[string]*zip.File, name string, read func(decoder *xml.Decoder) error) error {
+	file, ok := files[name]
+	if !ok {
+		return fmt.Errorf("xlsx is missing %v", name)
+	}
+
+	tooLarge := fmt.Errorf("xlsx %v is larger than %v MB", name, maxEntrySize>>20)
+
+	if file.UncompressedSize64 > maxEntrySize {
+		return tooLarge
+	}
+
+	reader, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	limited := &io.LimitedReader{R: reader, N: maxEntrySize + 1}
+	err = read(xml.NewDecoder(limited))
+
+	if limited.N <= 0 {
+		return tooLarge
+	}
+
+	return err
+}
+
+// columnIndex turns the letters of a cell reference like "AB12" into a
+// zero-based column number. It returns -1 when the reference has no letters
+// or names a column past maxColumns.
+func columnIndex(ref string) int {
+	index := 0
+	for _, char := range ref {
+		if char < 'A' || char > 'Z' {
+			break
+		}
+		index = index*26 + int(char-'A'+1)
+		if index > maxColumns {
+			return -1
+		}
+	}
+	return index - 1
+}
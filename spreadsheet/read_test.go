@@ -0,0 +1,188 @@
+package spreadsheet
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// buildXLSX zips a minimal workbook whose first sheet holds sheetData.
+func buildXLSX(t *testing.T, sheetData string, sharedStrings ...string) []byte {
+	t.Helper()
+
+	files := map[string]string{
+		"xl/workbook.xml": `<workbook xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="Sheet1" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships><Relationship Id="rId1" Target="worksheets/sheet1.xml"/></Relationships>`,
+		"xl/worksheets/sheet1.xml":   `<worksheet><sheetData>` + sheetData + `</sheetData></worksheet>`,
+	}
+
+	if len(sharedStrings) > 0 {
+		var sst strings.Builder
+		sst.WriteString("<sst>")
+		for _, s := range sharedStrings {
+			sst.WriteString("<si><t>" + s + "</t></si>")
+		}
+		sst.WriteString("</sst>")
+		files["xl/sharedStrings.xml"] = sst.String()
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestReadXLSX(t *testing.T) {
+	tests := []struct {
+		name    string
+		sheet   string
+		shared  []string
+		want    [][]string
+		wantErr string
+	}{
+		{
+			name:   "shared and inline strings",
+			sheet:  `<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="inlineStr"><is><t>price</t></is></c></row>`,
+			shared: []string{"name"},
+			want:   [][]string{{"name", "price"}},
+		},
+		{
+			name:  "numbers in exponent form",
+			sheet: `<row r="1"><c r="A1"><v>5.012345678901E12</v></c><c r="B1" t="b"><v>1</v></c></row>`,
+			want:  [][]string{{"5012345678901", "true"}},
+		},
+		{
+			name:  "gaps between rows and cells",
+			sheet: `<row r="1"><c r="A1"><v>1</v></c></row><row r="3"><c r="C3"><v>3</v></c></row>`,
+			want:  [][]string{{"1"}, nil, {"", "", "3"}},
+		},
+		{
+			name:  "cells out of order",
+			sheet: `<row r="1"><c r="B1"><v>b</v></c><c r="A1"><v>a</v></c></row>`,
+			want:  [][]string{{"a", "b"}},
+		},
+		{
+			name:  "rows without references",
+			sheet: `<row><c><v>1</v></c><c><v>2</v></c></row><row><c><v>3</v></c></row>`,
+			want:  [][]string{{"1", "2"}, {"3"}},
+		},
+		{
+			name:    "cell reference without column letters",
+			sheet:   `<row r="1"><c r="1"><v>1</v></c></row>`,
+			wantErr: "invalid reference",
+		},
+		{
+			name:    "column past the widest sheet",
+			sheet:   `<row r="1"><c r="ZZZZZZZZZZZZZZ1"><v>1</v></c></row>`,
+			wantErr: "invalid reference",
+		},
+		{
+			name:    "negative row",
+			sheet:   `<row r="-3"><c r="A1"><v>1</v></c></row>`,
+			wantErr: "invalid reference",
+		},
+		{
+			name:    "row past the limit",
+			sheet:   `<row r="2000000000"><c r="A2000000000"><v>1</v></c></row>`,
+			wantErr: "more than 10 rows",
+		},
+		{
+			name:    "too many rows without references",
+			sheet:   strings.Repeat(`<row><c><v>1</v></c></row>`, 11),
+			wantErr: "more than 10 rows",
+		},
+		{
+			name:    "missing shared string",
+			sheet:   `<row r="1"><c r="A1" t="s"><v>4</v></c></row>`,
+			wantErr: "missing shared string",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			content := buildXLSX(t, test.sheet, test.shared...)
+
+			rows, err := ReadXLSX(bytes.NewReader(content), int64(len(content)), 10)
+
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("got error %v, want one containing %q", err, test.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ReadXLSX: %v", err)
+			}
+
+			if !reflect.DeepEqual(rows, test.want) {
+				t.Errorf("got %q, want %q", rows, test.want)
+			}
+		})
+	}
+}
+
+func TestReadXLSXRejectsOversizedEntries(t *testing.T) {
+	content := buildXLSX(t, strings.Repeat(" ", maxEntrySize+1))
+
+	_, err := ReadXLSX(bytes.NewReader(content), int64(len(content)), 10)
+	if err == nil || !strings.Contains(err.Error(), "larger than") {
+		t.Fatalf("got error %v, want the entry to be rejected as too large", err)
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    [][]string
+		wantErr string
+	}{
+		{
+			name:  "byte order mark and ragged rows",
+			input: "\ufeffname,price\nrice,100,extra\n",
+			want:  [][]string{{"name", "price"}, {"rice", "100", "extra"}},
+		},
+		{
+			name:    "too many rows",
+			input:   "a\nb\nc\n",
+			wantErr: "more than 2 rows",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rows, err := ReadCSV(strings.NewReader(test.input), 2)
+
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("got error %v, want one containing %q", err, test.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ReadCSV: %v", err)
+			}
+
+			if !reflect.DeepEqual(rows, test.want) {
+				t.Errorf("got %q, want %q", rows, test.want)
+			}
+		})
+	}
+}
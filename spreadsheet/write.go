@@ -0,0 +1,287 @@
+package spreadsheet
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RowSource yields rows one at a time so large exports never sit in memory.
+type RowSource interface {
+	Columns() []string
+	Next() bool
+	Row() ([]interface{}, error)
+	Err() error
+	Close() error
+}
+
+func IsValidFormat(format string) bool {
+	switch format {
+	case "csv", "xlsx", "ndjson":
+		return true
+	default:
+		return false
+	}
+}
+
+func ContentType(format string) string {
+	switch format {
+	case "xlsx":
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case "ndjson":
+		return "application/x-ndjson"
+	default:
+		return "text/csv"
+	}
+}
+
+// Stream writes every row of src to w in the given format and closes src.
+func Stream(w io.Writer, format string, src RowSource) error {
+	defer src.Close()
+
+	var writer rowWriter
+	switch format {
+	case "csv":
+		writer = newCSVWriter(w)
+	case "xlsx":
+		writer = newXLSXWriter(w)
+	case "ndjson":
+		writer = newNDJSONWriter(w)
+	default:
+		return fmt.Errorf("unsupported export format %v", format)
+	}
+
+	if err := writer.header(src.Columns()); err != nil {
+		return err
+	}
+
+	for src.Next() {
+		values, err := src.Row()
+		if err != nil {
+			return err
+		}
+
+		if err := writer.row(values); err != nil {
+			return err
+		}
+	}
+
+	if err := src.Err(); err != nil {
+		return err
+	}
+
+	return writer.close()
+}
+
+type rowWriter interface {
+	header(columns []string) error
+	row(values []interface{}) error
+	close() error
+}
+
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return v.Format(time.RFC3339)
+	case *time.Time:
+		if v == nil {
+			return ""
+		}
+		return formatValue(*v)
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+type csvWriter struct {
+	out *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	return &csvWriter{out: csv.NewWriter(w)}
+}
+
+func (c *csvWriter) header(columns []string) error {
+	return c.out.Write(columns)
+}
+
+func (c *csvWriter) row(values []interface{}) error {
+	cells := make([]string, len(values))
+	for i, value := range values {
+		cells[i] = formatValue(value)
+
+		//Stop spreadsheet programs from running text cells as formulas
+		if text, ok := value.(string); ok && text != "" && strings.ContainsRune("=+-@", rune(text[0])) {
+			cells[i] = "'" + cells[i]
+		}
+	}
+
+	if err := c.out.Write(cells); err != nil {
+		return err
+	}
+
+	c.out.Flush()
+	return c.out.Error()
+}
+
+func (c *csvWriter) close() error {
+	c.out.Flush()
+	return c.out.Error()
+}
+
+type ndjsonWriter struct {
+	out     *json.Encoder
+	columns []string
+}
+
+func newNDJSONWriter(w io.Writer) *ndjsonWriter {
+	return &ndjsonWriter{out: json.NewEncoder(w)}
+}
+
+func (n *ndjsonWriter) header(columns []string) error {
+	n.columns = columns
+	return nil
+}
+
+func (n *ndjsonWriter) row(values []interface{}) error {
+	object := make(map[string]interface{}, len(values))
+	for i, value := range values {
+		if i < len(n.columns) {
+			object[n.columns[i]] = value
+		}
+	}
+	return n.out.Encode(object)
+}
+
+func (n *ndjsonWriter) close() error {
+	return nil
+}
+
+// xlsxWriter streams a single-sheet workbook. Text is written as inline
+// strings so no shared string table has to be collected up front.
+type xlsxWriter struct {
+	archive *zip.Writer
+	sheet   *bufio.Writer
+	rows    int
+}
+
+func newXLSXWriter(w io.Writer) *xlsxWriter {
+	return &xlsxWriter{archive: zip.NewWriter(w)}
+}
+
+const (
+	xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/><Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/></Types>`
+	xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`
+	xlsxWorkbookXML = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Export" sheetId="1" r:id="rId1"/></sheets></workbook>`
+	xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/><Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/></Relationships>`
+	xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><fonts count="1"><font><sz val="11"/><name val="Calibri"/></font></fonts><fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills><borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders><cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs><cellXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/></cellXfs></styleSheet>`
+)
+
+func (x *xlsxWriter) header(columns []string) error {
+	parts := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", xlsxWorkbookXML},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/styles.xml", xlsxStyles},
+	}
+
+	for _, part := range parts {
+		file, err := x.archive.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(file, part.content); err != nil {
+			return err
+		}
+	}
+
+	file, err := x.archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+
+	x.sheet = bufio.NewWriter(file)
+	x.sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	x.sheet.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+
+	values := make([]interface{}, len(columns))
+	for i, column := range columns {
+		values[i] = column
+	}
+	return x.row(values)
+}
+
+func (x *xlsxWriter) row(values []interface{}) error {
+	x.rows = x.rows + 1
+	fmt.Fprintf(x.sheet, `<row r="%d">`, x.rows)
+
+	for i, value := range values {
+		ref := columnName(i) + strconv.Itoa(x.rows)
+
+		switch v := value.(type) {
+		case nil:
+			continue
+		case int, int64, int32, uint, uint64, uint32, float64, float32:
+			fmt.Fprintf(x.sheet, `<c r="%s"><v>%v</v></c>`, ref, v)
+		case bool:
+			b := 0
+			if v {
+				b = 1
+			}
+			fmt.Fprintf(x.sheet, `<c r="%s" t="b"><v>%d</v></c>`, ref, b)
+		default:
+			fmt.Fprintf(x.sheet, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">`, ref)
+			if err := xml.EscapeText(x.sheet, []byte(formatValue(v))); err != nil {
+				return err
+			}
+			x.sheet.WriteString(`</t></is></c>`)
+		}
+	}
+
+	x.sheet.WriteString(`</row>`)
+
+	//Keep memory flat by handing each row to the zip stream as it is built
+	return x.sheet.Flush()
+}
+
+func (x *xlsxWriter) close() error {
+	x.sheet.WriteString(`</sheetData></worksheet>`)
+	if err := x.sheet.Flush(); err != nil {
+		return err
+	}
+	return x.archive.Close()
+}
+
+// columnName turns a zero-based column number into letters, the reverse of
+// columnIndex.
+func columnName(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+	return name
+}
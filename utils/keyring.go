@@ -0,0 +1,310 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// SigningKey is one entry of the keyring. Keys kept only to verify tokens
+// issued before a rotation have no private half.
+type SigningKey struct {
+	ID      string
+	Method  jwt.SigningMethod
+	private interface{}
+	public  interface{}
+}
+
+// Keyring signs new tokens with its active key and accepts tokens signed by
+// any key it holds, so rotating keys does not log everyone out.
+type Keyring struct {
+	active *SigningKey
+	keys   map[string]*SigningKey
+}
+
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+var keyring = &Keyring{keys: map[string]*SigningKey{}}
+
+func NewKeyring(active *SigningKey, previous ...*SigningKey) (*Keyring, error) {
+	if active == nil || active.private == nil {
+		return nil, errors.New("the active signing key must include a private key")
+	}
+
+	ring := &Keyring{active: active, keys: map[string]*SigningKey{active.ID: active}}
+
+	for _, key := range previous {
+		if _, ok := ring.keys[key.ID]; ok {
+			return nil, fmt.Errorf("signing key id %v is used more than once", key.ID)
+		}
+
+		ring.keys[key.ID] = key
+	}
+
+	return ring, nil
+}
+
+// LoadKeyring reads the signing configuration from the environment:
+//
+//	JWT_ALGORITHM         HS256, RS256 or EdDSA, inferred from the key when unset
+//	JWT_SECRET            HS256 secret, at least 32 bytes
+//	JWT_PRIVATE_KEY_FILE  PEM private key for RS256 or EdDSA
+//	JWT_KEY_ID            kid of the active key, derived from the key when unset
+//	JWT_PREVIOUS_KEYS     kid=file.pem,... still accepted for verification
+//	JWT_PREVIOUS_SECRETS  kid=secret,... HS256 secrets still accepted
+func LoadKeyring() error {
+	active, err := loadActiveKey()
+	if err != nil {
+		return err
+	}
+
+	var previous []*SigningKey
+
+	for _, entry := range splitKeyList(os.Getenv("JWT_PREVIOUS_KEYS")) {
+		key, err := readPEMKey(entry[1], false)
+		if err != nil {
+			return fmt.Errorf("JWT_PREVIOUS_KEYS %v: %v", entry[0], err)
+		}
+
+		key.ID = entry[0]
+		previous = append(previous, key)
+	}
+
+	for _, entry := range splitKeyList(os.Getenv("JWT_PREVIOUS_SECRETS")) {
+		previous = append(previous, &SigningKey{ID: entry[0], Method: jwt.SigningMethodHS256, public: []byte(entry[1])})
+	}
+
+	ring, err := NewKeyring(active, previous...)
+	if err != nil {
+		return err
+	}
+
+	keyring = ring
+	return nil
+}
+
+func loadActiveKey() (*SigningKey, error) {
+	algorithm := os.Getenv("JWT_ALGORITHM")
+	var key *SigningKey
+
+	if path := os.Getenv("JWT_PRIVATE_KEY_FILE"); path != "" {
+		var err error
+		if key, err = readPEMKey(path, true); err != nil {
+			return nil, fmt.Errorf("JWT_PRIVATE_KEY_FILE: %v", err)
+		}
+	} else if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		if len(secret) < 32 {
+			return nil, errors.New("JWT_SECRET must be at least 32 bytes")
+		}
+
+		key = &SigningKey{Method: jwt.SigningMethodHS256, private: []byte(secret), public: []byte(secret)}
+	} else {
+		return nil, errors.New("set JWT_SECRET or JWT_PRIVATE_KEY_FILE to sign tokens")
+	}
+
+	if algorithm != "" && algorithm != key.Method.Alg() {
+		return nil, fmt.Errorf("JWT_ALGORITHM is %v but the configured key is for %v", algorithm, key.Method.Alg())
+	}
+
+	key.ID = os.Getenv("JWT_KEY_ID")
+	if key.ID == "" {
+		key.ID = key.thumbprint()
+	}
+
+	return key, nil
+}
+
+// splitKeyList parses "kid=value,kid=value".
+func splitKeyList(value string) [][2]string {
+	var entries [][2]string
+
+	for _, item := range strings.Split(value, ",") {
+		kid, rest, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok || kid == "" {
+			continue
+		}
+
+		entries = append(entries, [2]string{kid, rest})
+	}
+
+	return entries
+}
+
+// readPEMKey loads an RSA or Ed25519 key. Private keys are accepted anywhere,
+// public keys only when the private half is not needed.
+func readPEMKey(path string, needPrivate bool) (*SigningKey, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed interface{}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %v", block.Type)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	key := &SigningKey{}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Method, key.private, key.public = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.Method, key.public = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.Method, key.private, key.public = jwt.SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.Method, key.public = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, fmt.Errorf("unsupported key type %T, use RSA or Ed25519", parsed)
+	}
+
+	if rsaKey, ok := key.public.(*rsa.PublicKey); ok && rsaKey.N.BitLen() < 2048 {
+		return nil, errors.New("RSA keys must be at least 2048 bits")
+	}
+
+	if needPrivate && key.private == nil {
+		return nil, errors.New("a private key is required")
+	}
+
+	return key, nil
+}
+
+// thumbprint derives a stable kid from the verification key.
+func (k *SigningKey) thumbprint() string {
+	var material []byte
+
+	switch public := k.public.(type) {
+	case []byte:
+		material = public
+	default:
+		material, _ = x509.MarshalPKIXPublicKey(public)
+	}
+
+	sum := sha256.Sum256(material)
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
+}
+
+func (k *Keyring) sign(claims jwt.Claims) (string, error) {
+	if k.active == nil {
+		return "", errors.New("no signing key configured")
+	}
+
+	t := jwt.NewWithClaims(k.active.Method, claims)
+	t.Header["kid"] = k.active.ID
+
+	return t.SignedString(k.active.private)
+}
+
+// verificationKey picks the key named by the token's kid and refuses tokens
+// whose alg does not match it, so an RSA public key can never be used as an
+// HMAC secret.
+func (k *Keyring) verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	key, ok := k.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("signing key %v does not use %v", kid, token.Method.Alg())
+	}
+
+	return key.public, nil
+}
+
+func (k *Keyring) methods() []string {
+	var methods []string
+	seen := map[string]bool{}
+
+	for _, key := range k.keys {
+		if !seen[key.Method.Alg()] {
+			seen[key.Method.Alg()] = true
+			methods = append(methods, key.Method.Alg())
+		}
+	}
+
+	return methods
+}
+
+// JWKS publishes the public half of every asymmetric key. HMAC secrets are
+// never listed.
+func JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+
+	ids := make([]string, 0, len(keyring.keys))
+	for id := range keyring.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		key := keyring.keys[id]
+
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				KeyType:   "RSA",
+				KeyID:     key.ID,
+				Use:       "sig",
+				Algorithm: key.Method.Alg(),
+				N:         base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+				E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				KeyType:   "OKP",
+				KeyID:     key.ID,
+				Use:       "sig",
+				Algorithm: key.Method.Alg(),
+				Curve:     "Ed25519",
+				X:         base64.RawURLEncoding.EncodeToString(public),
+			})
+		}
+	}
+
+	return set
+}
@@ -0,0 +1,176 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func writeEd25519Key(t *testing.T) string {
+	t.Helper()
+
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func setKeyEnv(t *testing.T, env map[string]string) {
+	t.Helper()
+
+	for _, name := range []string{"JWT_ALGORITHM", "JWT_SECRET", "JWT_PRIVATE_KEY_FILE", "JWT_KEY_ID", "JWT_PREVIOUS_KEYS", "JWT_PREVIOUS_SECRETS"} {
+		t.Setenv(name, env[name])
+	}
+}
+
+func TestLoadKeyringRejectsBadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr string
+	}{
+		{name: "no key", env: map[string]string{}, wantErr: "set JWT_SECRET"},
+		{name: "short secret", env: map[string]string{"JWT_SECRET": "short"}, wantErr: "at least 32 bytes"},
+		{name: "algorithm mismatch", env: map[string]string{"JWT_SECRET": testSecret, "JWT_ALGORITHM": "RS256"}, wantErr: "configured key is for HS256"},
+		{name: "duplicate kid", env: map[string]string{"JWT_SECRET": testSecret, "JWT_KEY_ID": "a", "JWT_PREVIOUS_SECRETS": "a=" + testSecret}, wantErr: "used more than once"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setKeyEnv(t, test.env)
+
+			err := LoadKeyring()
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Fatalf("got error %v, want one containing %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestTokensSurviveKeyRotation(t *testing.T) {
+	setKeyEnv(t, map[string]string{"JWT_SECRET": testSecret, "JWT_KEY_ID": "old"})
+	if err := LoadKeyring(); err != nil {
+		t.Fatalf("LoadKeyring: %v", err)
+	}
+
+	userID := uuid.New()
+
+	oldToken, _, err := GenerateToken(userID)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+
+	if set := JWKS(); len(set.Keys) != 0 {
+		t.Errorf("JWKS lists %d keys, HMAC secrets must not be published", len(set.Keys))
+	}
+
+	setKeyEnv(t, map[string]string{
+		"JWT_PRIVATE_KEY_FILE": writeEd25519Key(t),
+		"JWT_KEY_ID":           "new",
+		"JWT_PREVIOUS_SECRETS": "old=" + testSecret,
+	})
+	if err := LoadKeyring(); err != nil {
+		t.Fatalf("LoadKeyring after rotation: %v", err)
+	}
+
+	claims, err := ParseToken(oldToken)
+	if err != nil {
+		t.Fatalf("token signed before the rotation: %v", err)
+	}
+	if claims.UserID != userID {
+		t.Errorf("got user %v, want %v", claims.UserID, userID)
+	}
+
+	newToken, _, err := GenerateToken(userID)
+	if err != nil {
+		t.Fatalf("GenerateToken after rotation: %v", err)
+	}
+
+	parsed, _, err := jwt.NewParser().ParseUnverified(newToken, jwt.MapClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Header["kid"] != "new" || parsed.Method.Alg() != "EdDSA" {
+		t.Errorf("new token signed with kid %v and %v, want new and EdDSA", parsed.Header["kid"], parsed.Method.Alg())
+	}
+
+	if _, err := ParseToken(newToken); err != nil {
+		t.Errorf("token signed after the rotation: %v", err)
+	}
+
+	set := JWKS()
+	if len(set.Keys) != 1 || set.Keys[0].KeyID != "new" || set.Keys[0].Curve != "Ed25519" {
+		t.Errorf("got JWKS %+v, want only the Ed25519 key", set.Keys)
+	}
+}
+
+func TestParseTokenRejectsForgedTokens(t *testing.T) {
+	setKeyEnv(t, map[string]string{"JWT_PRIVATE_KEY_FILE": writeEd25519Key(t), "JWT_KEY_ID": "ed"})
+	if err := LoadKeyring(); err != nil {
+		t.Fatalf("LoadKeyring: %v", err)
+	}
+
+	claims := accessClaims{
+		UserID: uuid.New(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	}
+
+	//An HS256 token keyed with the published public key must not verify
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	forged.Header["kid"] = "ed"
+	forgedString, err := forged.SignedString([]byte(keyring.active.public.(ed25519.PublicKey)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ParseToken(forgedString); err == nil {
+		t.Error("HS256 token signed with the public key was accepted")
+	}
+
+	unknown := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	unknown.Header["kid"] = "missing"
+	unknownString, err := unknown.SignedString(keyring.active.private)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ParseToken(unknownString); err == nil {
+		t.Error("token with an unknown kid was accepted")
+	}
+
+	purposeToken, _, err := GeneratePurposeToken(uuid.New(), "verify_email", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ParseToken(purposeToken); err == nil {
+		t.Error("purpose token was accepted as an access token")
+	}
+}
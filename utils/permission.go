@@ -0,0 +1,150 @@
+package utils
+
+import (
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Permission string
+
+const (
+	PermProductRead     Permission = "product:read"
+	PermProductWrite    Permission = "product:write"
+	PermProductDelete   Permission = "product:delete"
+	PermCategoryRead    Permission = "category:read"
+	PermCategoryWrite   Permission = "category:write"
+	PermStockRead       Permission = "stock:read"
+	PermStockAdjust     Permission = "stock:adjust"
+	PermStockOverwrite  Permission = "stock:overwrite"
+	PermStockLedger     Permission = "stock:ledger"
+	PermOrderRead       Permission = "order:read"
+	PermOrderCreate     Permission = "order:create"
+	PermOrderRefund     Permission = "order:refund"
+	PermOrderDelete     Permission = "order:delete"
+	PermSupplierRead    Permission = "supplier:read"
+	PermSupplierWrite   Permission = "supplier:write"
+	PermPurchaseRead    Permission = "purchase_order:read"
+	PermPurchaseWrite   Permission = "purchase_order:write"
+	PermPurchaseReceive Permission = "purchase_order:receive"
+	PermStocktakeRead   Permission = "stocktake:read"
+	PermStocktakeCount  Permission = "stocktake:count"
+	PermStocktakeManage Permission = "stocktake:manage"
+	PermLocationRead    Permission = "location:read"
+	PermLocationManage  Permission = "location:manage"
+	PermTransferRead    Permission = "transfer:read"
+	PermTransferRequest Permission = "transfer:request"
+	PermTransferReceive Permission = "transfer:receive"
+	PermTransferManage  Permission = "transfer:manage"
+	PermReportView      Permission = "report:view"
+	PermUserRead        Permission = "user:read"
+	PermUserExport      Permission = "user:export"
+	PermUserManage      Permission = "user:manage"
+	PermRoleManage      Permission = "role:manage"
+	PermAuditView       Permission = "audit:view"
+)
+
+// permissionLevels is the RoleLevel each permission used to require. It
+// seeds the built-in roles so they keep exactly the access they had.
+var permissionLevels = map[Permission]int{
+	PermProductRead:     1,
+	PermProductWrite:    3,
+	PermProductDelete:   3,
+	PermCategoryRead:    1,
+	PermCategoryWrite:   3,
+	PermStockRead:       2,
+	PermStockAdjust:     2,
+	PermStockOverwrite:  3,
+	PermStockLedger:     3,
+	PermOrderRead:       2,
+	PermOrderCreate:     2,
+	PermOrderRefund:     3,
+	PermOrderDelete:     4,
+	PermSupplierRead:    2,
+	PermSupplierWrite:   3,
+	PermPurchaseRead:    2,
+	PermPurchaseWrite:   3,
+	PermPurchaseReceive: 2,
+	PermStocktakeRead:   2,
+	PermStocktakeCount:  2,
+	PermStocktakeManage: 3,
+	PermLocationRead:    1,
+	PermLocationManage:  4,
+	PermTransferRead:    2,
+	PermTransferRequest: 2,
+	PermTransferReceive: 2,
+	PermTransferManage:  3,
+	PermReportView:      3,
+	PermUserRead:        1,
+	PermUserExport:      3,
+	PermUserManage:      4,
+	PermRoleManage:      4,
+	PermAuditView:       4,
+}
+
+func AllPermissions() []Permission {
+	permissions := make([]Permission, 0, len(permissionLevels))
+	for permission := range permissionLevels {
+		permissions = append(permissions, permission)
+	}
+
+	sort.Slice(permissions, func(i, j int) bool {
+		return permissions[i] < permissions[j]
+	})
+
+	return permissions
+}
+
+func IsValidPermission(permission Permission) bool {
+	_, ok := permissionLevels[permission]
+	return ok
+}
+
+// DefaultPermissions lists what a built-in role is granted when first seeded.
+func DefaultPermissions(role UserRole) []Permission {
+	level := RoleLevel(role)
+
+	var permissions []Permission
+	for _, permission := range AllPermissions() {
+		if permissionLevels[permission] <= level {
+			permissions = append(permissions, permission)
+		}
+	}
+
+	return permissions
+}
+
+const permissionsKey = "investrite:permissions"
+
+// SetPermissions stores what the signed in user may do for the handlers that
+// decide part of their access at runtime.
+func SetPermissions(ctx *gin.Context, permissions []Permission) {
+	ctx.Set(permissionsKey, permissions)
+}
+
+func HasPermission(ctx *gin.Context, permission Permission) bool {
+	value, ok := ctx.Get(permissionsKey)
+	if !ok {
+		return false
+	}
+
+	for _, granted := range value.([]Permission) {
+		if granted == permission {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HasPermissions reports whether the signed in user holds every permission
+// listed, which is what they need to hand those permissions to someone else.
+func HasPermissions(ctx *gin.Context, permissions []Permission) bool {
+	for _, permission := range permissions {
+		if !HasPermission(ctx, permission) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
@@ -65,30 +66,65 @@ func GetAccessToken(header *http.Header) (string, error) {
 	return vals[1], nil
 }
 
-var secretKey []byte = []byte("secret-key")
+const AccessTokenTTL = 15 * time.Minute
 
-func GenerateToken(userID uuid.UUID) (string, error) {
-	var (
-		t *jwt.Token
-		s string
-	)
+// Purpose separates access tokens from single-purpose tokens such as a
+// password reset link, so one can never be used in place of the other.
+type accessClaims struct {
+	UserID  uuid.UUID `json:"user-id"`
+	Purpose string    `json:"purpose,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// TokenClaims is what a verified token says about its holder.
+type TokenClaims struct {
+	UserID    uuid.UUID
+	ID        string
+	ExpiresAt time.Time
+}
+
+// GenerateToken issues a short-lived access token. Its jti lets a single token
+// be revoked on logout before it expires.
+func GenerateToken(userID uuid.UUID) (string, *TokenClaims, error) {
+	return GeneratePurposeToken(userID, "", AccessTokenTTL)
+}
+
+func GeneratePurposeToken(userID uuid.UUID, purpose string, ttl time.Duration) (string, *TokenClaims, error) {
+	now := time.Now()
 
-	t = jwt.NewWithClaims(jwt.SigningMethodHS256,
-		jwt.MapClaims{
-			"user-id": userID,
-		})
-	s, err := t.SignedString(secretKey)
+	claims := TokenClaims{
+		UserID:    userID,
+		ID:        uuid.NewString(),
+		ExpiresAt: now.Add(ttl),
+	}
+
+	s, err := keyring.sign(accessClaims{
+		UserID:  userID,
+		Purpose: purpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        claims.ID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(claims.ExpiresAt),
+		},
+	})
 
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
-	return s, nil
+	return s, &claims, nil
 }
 
-func ParseToken(tokenString string) (interface{}, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return secretKey, nil
-	})
+// ParseToken verifies the signature and rejects tokens that are expired or
+// were issued without an expiry.
+func ParseToken(tokenString string) (*TokenClaims, error) {
+	return ParsePurposeToken(tokenString, "")
+}
+
+func ParsePurposeToken(tokenString string, purpose string) (*TokenClaims, error) {
+	var claims accessClaims
+
+	token, err := jwt.ParseWithClaims(tokenString, &claims, keyring.verificationKey,
+		jwt.WithValidMethods(keyring.methods()), jwt.WithExpirationRequired(), jwt.WithIssuedAt())
 
 	if err != nil {
 		return nil, err
@@ -98,13 +134,19 @@ func ParseToken(tokenString string) (interface{}, error) {
 		return nil, errors.New("invalid token generated")
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
+	if claims.ID == "" || claims.UserID == uuid.Nil {
+		return nil, errors.New("token is missing required claims")
+	}
 
-	if !ok {
-		return nil, err
+	if claims.Purpose != purpose {
+		return nil, errors.New("token was not issued for this purpose")
 	}
 
-	return claims["user-id"], nil
+	return &TokenClaims{
+		UserID:    claims.UserID,
+		ID:        claims.ID,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}, nil
 }
 
 func GetIDInRoute(ctx *gin.Context, IDName string) (uuid.UUID, error) {
@@ -123,6 +165,16 @@ func GetIDInRoute(ctx *gin.Context, IDName string) (uuid.UUID, error) {
 	return id, nil
 }
 
+func GetOptionalIDInQuery(ctx *gin.Context, key string) (uuid.UUID, error) {
+	value := ctx.Query(key)
+
+	if value == "" {
+		return uuid.Nil, nil
+	}
+
+	return uuid.Parse(value)
+}
+
 func IsValidUUID(uuid string) bool {
 	// Define the regex pattern
 	var uuidRegex = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[1-5][0-9a-fA-F]{3}-[89abAB][0-9a-fA-F]{3}-[0-9a-fA-F]{12}$`)
@@ -138,15 +190,8 @@ const (
 	ViewerRole     UserRole = "viewer"
 )
 
-func IsValidRole(role UserRole) bool {
-	switch role {
-	case AdminRole, SupervisorRole, OperatorRole, ViewerRole:
-		return true
-	default:
-		return false
-	}
-}
-
+// RoleLevel ranks the built-in roles. Access is decided by permissions now;
+// the levels only seed what each built-in role is granted.
 func RoleLevel(role UserRole) int {
 	switch role {
 	case AdminRole:
@@ -161,3 +206,57 @@ func RoleLevel(role UserRole) int {
 		return 0
 	}
 }
+
+// IsValidBarcode accepts UPC-A (12 digit) and EAN-13 codes whose final digit
+// matches the GS1 check digit.
+func IsValidBarcode(code string) bool {
+	if len(code) != 12 && len(code) != 13 {
+		return false
+	}
+
+	sum := 0
+	for i := len(code) - 2; i >= 0; i-- {
+		digit := code[i]
+		if digit < '0' || digit > '9' {
+			return false
+		}
+
+		//Weights alternate 3,1,... counting leftwards from the check digit
+		weight := 1
+		if (len(code)-2-i)%2 == 0 {
+			weight = 3
+		}
+		sum = sum + int(digit-'0')*weight
+	}
+
+	check := code[len(code)-1]
+	if check < '0' || check > '9' {
+		return false
+	}
+
+	return (10-sum%10)%10 == int(check-'0')
+}
+
+const auditEntryKey = "investrite:audit_entry"
+
+// AuditEntry lets a handler tell the audit middleware about the entity it
+// touched when the route alone cannot, such as the id of a newly created row.
+type AuditEntry struct {
+	EntityID uuid.UUID
+	Before   interface{}
+	After    interface{}
+}
+
+func SetAuditEntry(ctx *gin.Context, entry AuditEntry) {
+	ctx.Set(auditEntryKey, entry)
+}
+
+func GetAuditEntry(ctx *gin.Context) (AuditEntry, bool) {
+	value, ok := ctx.Get(auditEntryKey)
+	if !ok {
+		return AuditEntry{}, false
+	}
+
+	entry, ok := value.(AuditEntry)
+	return entry, ok
+}